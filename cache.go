@@ -0,0 +1,148 @@
+package smapping
+
+import (
+	"fmt"
+	"reflect"
+	s "strings"
+	"sync"
+)
+
+// typeCaps is the conversion capability of a type.
+type typeCaps struct {
+	// isTime is true for time.Time and the pointer to it.
+	isTime  bool
+	encoder bool
+	decoder bool
+
+	textMarshaler   bool
+	textUnmarshaler bool
+	jsonMarshaler   bool
+	jsonUnmarshaler bool
+}
+
+// capsCache caches *typeCaps by reflect.Type.
+var capsCache sync.Map
+
+func capsOf(typ reflect.Type) *typeCaps {
+	if c, ok := capsCache.Load(typ); ok {
+		return c.(*typeCaps)
+	}
+	c := &typeCaps{
+		isTime: typ.Name() == "Time" ||
+			typ.Kind() == reflect.Ptr && typ.Elem().Name() == "Time",
+		encoder: implements(typ, mapEncoderI),
+		decoder: implements(typ, mapDecoderI),
+
+		textMarshaler:   implements(typ, textMarshalerI),
+		textUnmarshaler: implements(typ, textUnmarshalerI),
+		jsonMarshaler:   implements(typ, jsonMarshalerI),
+		jsonUnmarshaler: implements(typ, jsonUnmarshalerI),
+	}
+	actual, _ := capsCache.LoadOrStore(typ, c)
+	return actual.(*typeCaps)
+}
+
+type planKey struct {
+	typ reflect.Type
+	tag string
+}
+
+// structPlan is the compiled fields of a struct type for a tag.
+type structPlan struct {
+	fields []field
+	// byKey indexes the fields by its key, the lower cased keys
+	// are included when the Mapper is case insensitive.
+	byKey map[string]field
+	// required are the fields with the required option.
+	required []field
+	// defaults are the fields with the default tag and nested are the
+	// struct fields having such fields at any depth.
+	defaults []field
+	nested   []field
+	// unmapped are the exported fields without key for the tag which
+	// have the validate tag or may have such nested fields.
+	unmapped []field
+	// structs are the nested struct fields with or without key for the
+	// tag, which are walked by MapTagsFlatten and FillStructDeflate.
+	structs []field
+}
+
+// plan returns the cached structPlan of the struct type typ for the tag
+// together with the fallback tags of the Mapper.
+func (m *Mapper) plan(typ reflect.Type, tag string) *structPlan {
+	key := planKey{typ: typ, tag: tag}
+	if p, ok := m.plans.Load(key); ok {
+		return p.(*structPlan)
+	}
+	fields := typeFields(typ, m.tags(tag)...)
+	if naming := m.config.KeyNaming; naming != nil {
+		for i := range fields {
+			if !fields[i].tagged {
+				fields[i].name = naming(fields[i].name)
+			}
+		}
+	}
+	p := &structPlan{
+		fields: fields,
+		byKey:  make(map[string]field, len(fields)),
+	}
+	for i := range fields {
+		fields[i].caps = capsOf(fields[i].typ)
+		fields[i].out = m.outputKey(typ, fields[i].index, fields[i].name)
+		p.byKey[fields[i].name] = fields[i]
+		if fields[i].opts.Contains("required") {
+			p.required = append(p.required, fields[i])
+		}
+		if nestedStruct(fields[i].typ) {
+			p.structs = append(p.structs, fields[i])
+		}
+		if fields[i].hasDef {
+			p.defaults = append(p.defaults, fields[i])
+		} else if nestedStruct(fields[i].typ) &&
+			hasDefaults(fields[i].typ, m.tags(tag), map[reflect.Type]bool{typ: true}) {
+			p.nested = append(p.nested, fields[i])
+		}
+	}
+	if tag != "" {
+		mapped := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			mapped[fmt.Sprint(f.index)] = true
+		}
+		for _, f := range typeFields(typ) {
+			if mapped[fmt.Sprint(f.index)] {
+				continue
+			}
+			if f.rules != "" || hasChildren(f.typ) {
+				p.unmapped = append(p.unmapped, f)
+			}
+			_, tagvalue, _ := lookupTags(typ.FieldByIndex(f.index), m.tags(tag))
+			if nestedStruct(f.typ) && tagvalue != "-" {
+				f.caps = capsOf(f.typ)
+				p.structs = append(p.structs, f)
+			}
+		}
+	}
+	if m.config.CaseInsensitive {
+		for _, f := range fields {
+			if _, ok := p.byKey[s.ToLower(f.name)]; !ok {
+				p.byKey[s.ToLower(f.name)] = f
+			}
+		}
+	}
+	actual, _ := m.plans.LoadOrStore(key, p)
+	return actual.(*structPlan)
+}
+
+// fallbackMappers caches the *Mapper of MapTagsWithDefault by its tags
+// so the plans are kept between the calls.
+var fallbackMappers sync.Map
+
+func fallbackMapper(tag string, defs []string) *Mapper {
+	key := s.Join(append([]string{tag}, defs...), "\x00")
+	if m, ok := fallbackMappers.Load(key); ok {
+		return m.(*Mapper)
+	}
+	m, _ := fallbackMappers.LoadOrStore(key,
+		NewMapper(Config{TagName: tag, FallbackTags: append([]string(nil), defs...)}))
+	return m.(*Mapper)
+}
@@ -0,0 +1,109 @@
+package smapping
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var nestedobj = embedObjs{
+	Objs: []*embedObj{
+		{1, "one", 1.1},
+		{2, "two", 2.2},
+		nil,
+		{4, "four", 3.3},
+		{5, "five", 4.4},
+	},
+}
+
+func TestMapper_concurrentPlans(t *testing.T) {
+	mapper := NewMapper(Config{TagName: "json"})
+	mapped := mapper.MapTags(&nestedobj)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var objs embedObjs
+			if err := mapper.FillStruct(&objs, mapped); err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs.Objs) != 5 || objs.Objs[4].FieldStr != "five" {
+				t.Errorf("wrong filled objects %#v", objs.Objs)
+			}
+			var embed embedEmbed
+			if err := mapper.FillStruct(&embed, Mapped{
+				"embed2": Mapped{"fieldInt": 2},
+			}); err != nil {
+				t.Error(err)
+				return
+			}
+			if embed.Embed2 == nil || embed.Embed2.FieldInt != 2 {
+				t.Errorf("wrong filled embed %#v", embed)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkMapTags_nested(b *testing.B) {
+	var m Mapped
+	for i := 0; i < b.N; i++ {
+		m = MapTags(&nestedobj, "json")
+	}
+	_ = m
+}
+
+func BenchmarkFillStructByTags_nested(b *testing.B) {
+	m := MapTags(&nestedobj, "json")
+	var s embedObjs
+	for i := 0; i < b.N; i++ {
+		FillStructByTags(&s, m, "json")
+	}
+	_ = s
+}
+
+func BenchmarkMapTagsWithDefault(b *testing.B) {
+	var m Mapped
+	for i := 0; i < b.N; i++ {
+		m = MapTagsWithDefault(sourceobj, "api", "json")
+	}
+	_ = m
+}
+
+func TestMapTagsFlatten_plan(t *testing.T) {
+	type Base struct {
+		ID      int       `json:"id"`
+		Created time.Time `json:"created"`
+	}
+	type inner struct {
+		Name string `json:"name"`
+	}
+	type outer struct {
+		*Base
+		Inner  inner  `json:"inner"`
+		Hidden inner  `json:"-"`
+		Other  *inner // untagged nested structs are walked too
+		Note   string `json:"note,omitempty"`
+	}
+	at := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	obj := outer{Base: &Base{ID: 1, Created: at}, Inner: inner{Name: "in"},
+		Hidden: inner{Name: "hidden"}}
+	expected := Mapped{"id": 1, "created": at, "name": "in"}
+	flat := MapTagsFlatten(&obj, "json")
+	if !reflect.DeepEqual(flat, expected) {
+		t.Errorf("expected %#v, got %#v", expected, flat)
+	}
+
+	var got outer
+	if err := FillStructDeflate(&got, Mapped{"id": 2, "created": at, "name": "x"}, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Base == nil || got.ID != 2 || !got.Created.Equal(at) ||
+		got.Inner.Name != "x" || got.Other == nil || got.Other.Name != "x" ||
+		got.Hidden.Name != "" {
+		t.Errorf("wrong deflated %#v", got)
+	}
+}
@@ -0,0 +1,118 @@
+package smapping
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+)
+
+var (
+	jsonMarshalerI   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerI = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// implements reports whether typ or the pointer to typ implements iface.
+func implements(typ, iface reflect.Type) bool {
+	return typ.Implements(iface) || reflect.PtrTo(typ).Implements(iface)
+}
+
+/*
+asInterface returns v as the interface iface. When only the pointer to
+the type of v implements iface, the address of v is used and the
+not addressable v is copied to an addressable temporary first.
+*/
+func asInterface(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+	if v.Type().Implements(iface) {
+		return v.Interface(), true
+	}
+	if !reflect.PtrTo(v.Type()).Implements(iface) {
+		return nil, false
+	}
+	if !v.CanAddr() {
+		tmp := reflect.New(v.Type())
+		tmp.Elem().Set(v)
+		return tmp.Interface(), true
+	}
+	return v.Addr().Interface(), true
+}
+
+// newDecoded allocates the value of typ and decodes into it with the
+// decode function of the pointer to the value, typ can be a pointer.
+func newDecoded(typ reflect.Type, decode func(ptr interface{}) error) (reflect.Value, error) {
+	elem := typ
+	if typ.Kind() == reflect.Ptr {
+		elem = typ.Elem()
+	}
+	ptr := reflect.New(elem)
+	if err := decode(ptr.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	if typ.Kind() == reflect.Ptr {
+		return ptr, nil
+	}
+	return ptr.Elem(), nil
+}
+
+// isTextValue reports whether val can be decoded with UnmarshalText.
+func isTextValue(val reflect.Value) bool {
+	return val.Kind() == reflect.String ||
+		val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8
+}
+
+// textValue decodes the string or bytes val into the new value of typ.
+func textValue(typ reflect.Type, val reflect.Value) (reflect.Value, error) {
+	var text []byte
+	if val.Kind() == reflect.String {
+		text = []byte(val.String())
+	} else {
+		text = val.Bytes()
+	}
+	return newDecoded(typ, func(ptr interface{}) error {
+		return ptr.(encoding.TextUnmarshaler).UnmarshalText(text)
+	})
+}
+
+// jsonValue decodes the JSON encoding of value into the new value of typ.
+func jsonValue(typ reflect.Type, value interface{}) (reflect.Value, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return newDecoded(typ, func(ptr interface{}) error {
+		return ptr.(json.Unmarshaler).UnmarshalJSON(data)
+	})
+}
+
+// encodedValue returns the value of fieldval encoded with MarshalText
+// or MarshalJSON if the type has one, the text takes precedence.
+// The JSON is decoded to the generic value just like json.Unmarshal
+// into interface{}. It's not ok when there's no such encoder.
+func (m *Mapper) encodedValue(fieldval reflect.Value, caps *typeCaps) (interface{}, bool, error) {
+	if caps.textMarshaler {
+		marshaler, ok := asInterface(fieldval, textMarshalerI)
+		if !ok {
+			return nil, false, nil
+		}
+		text, err := marshaler.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, true, err
+		}
+		return string(text), true, nil
+	}
+	if m.config.JSONCodec && caps.jsonMarshaler {
+		marshaler, ok := asInterface(fieldval, jsonMarshalerI)
+		if !ok {
+			return nil, false, nil
+		}
+		data, err := marshaler.(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, true, err
+		}
+		var val interface{}
+		if err := json.Unmarshal(data, &val); err != nil {
+			return nil, true, err
+		}
+		return val, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,194 @@
+package smapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// celsius is encoded as text while its MapEncoder takes precedence.
+type celsius float64
+
+func (c celsius) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%gC", float64(c))), nil
+}
+
+func (c *celsius) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%gC", (*float64)(c))
+	return err
+}
+
+type kelvin float64
+
+func (k kelvin) MapEncode() (interface{}, error) {
+	return float64(k) + 273.15, nil
+}
+
+func (k kelvin) MarshalText() ([]byte, error) {
+	return []byte("unused"), nil
+}
+
+// point is only encoded as JSON.
+type point struct {
+	x, y int
+}
+
+func (p point) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{p.x, p.y})
+}
+
+func (p *point) UnmarshalJSON(data []byte) error {
+	var xy []int
+	if err := json.Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	if len(xy) != 2 {
+		return fmt.Errorf("point needs 2 coordinates, got %d", len(xy))
+	}
+	p.x, p.y = xy[0], xy[1]
+	return nil
+}
+
+type codecs struct {
+	Addr   netip.Addr   `json:"addr"`
+	IP     net.IP       `json:"ip"`
+	Big    big.Int      `json:"big"`
+	BigPtr *big.Int     `json:"big_ptr"`
+	Temp   celsius      `json:"temp"`
+	Temps  []celsius    `json:"temps"`
+	Kelvin kelvin       `json:"kelvin"`
+	Point  point        `json:"point"`
+	Addrs  []netip.Addr `json:"addrs"`
+}
+
+func TestMapTags_textCodecs(t *testing.T) {
+	obj := codecs{
+		Addr:   netip.MustParseAddr("10.0.0.1"),
+		IP:     net.IPv4(127, 0, 0, 1),
+		Big:    *big.NewInt(42),
+		BigPtr: new(big.Int).Lsh(big.NewInt(1), 80),
+		Temp:   36.6,
+		Temps:  []celsius{1, 2},
+		Kelvin: 1,
+		Point:  point{1, 2},
+		Addrs:  []netip.Addr{netip.MustParseAddr("::1")},
+	}
+	mapped := MapTags(&obj, "json")
+	expected := Mapped{
+		"addr":    "10.0.0.1",
+		"ip":      "127.0.0.1",
+		"big":     "42",
+		"big_ptr": "1208925819614629174706176",
+		"temp":    "36.6C",
+		"temps":   []interface{}{"1C", "2C"},
+		"kelvin":  274.15,
+		"point":   Mapped{},
+		"addrs":   []interface{}{"::1"},
+	}
+	if !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("expected %#v, got %#v", expected, mapped)
+	}
+
+	var filled codecs
+	delete(mapped, "kelvin")
+	delete(mapped, "point")
+	if err := FillStructByTags(&filled, mapped, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if filled.Addr != obj.Addr || !filled.IP.Equal(obj.IP) ||
+		filled.Big.Cmp(&obj.Big) != 0 || filled.BigPtr.Cmp(obj.BigPtr) != 0 ||
+		filled.Temp != obj.Temp || !reflect.DeepEqual(filled.Temps, obj.Temps) ||
+		!reflect.DeepEqual(filled.Addrs, obj.Addrs) {
+		t.Errorf("expected %#v, got %#v", obj, filled)
+	}
+
+	err := FillStructByTags(&filled, Mapped{"addr": "not an address"}, "json")
+	if err == nil || !strings.Contains(err.Error(), "addr") {
+		t.Errorf("expected the addr error, got %v", err)
+	}
+}
+
+func TestMapper_jsonCodec(t *testing.T) {
+	mapper := NewMapper(Config{TagName: "json", JSONCodec: true})
+	obj := codecs{Point: point{3, 4}}
+	mapped := mapper.MapTags(&obj)
+	if !reflect.DeepEqual(mapped["point"], []interface{}{3.0, 4.0}) {
+		t.Errorf("expected point encoded as JSON, got %#v", mapped["point"])
+	}
+	var filled codecs
+	if err := mapper.FillStruct(&filled, Mapped{"point": []interface{}{5, 6}}); err != nil {
+		t.Fatal(err)
+	}
+	if filled.Point != (point{5, 6}) {
+		t.Errorf("expected point decoded from JSON, got %#v", filled.Point)
+	}
+	err := mapper.FillStruct(&filled, Mapped{"point": []interface{}{5}})
+	if err == nil || !strings.Contains(err.Error(), "2 coordinates") {
+		t.Errorf("expected the point error, got %v", err)
+	}
+}
+
+// upper has the pointer receiver MapEncoder.
+type upper struct {
+	value string
+}
+
+func (u *upper) MapEncode() (interface{}, error) {
+	return strings.ToUpper(u.value), nil
+}
+
+// labelSet has the value receiver MapDecoder filling the map.
+type labelSet map[string]bool
+
+func (l labelSet) MapDecode(x interface{}) error {
+	labels, ok := x.(string)
+	if !ok {
+		return fmt.Errorf("labels must be string, got %T", x)
+	}
+	for _, label := range strings.Split(labels, ",") {
+		l[label] = true
+	}
+	return nil
+}
+
+func TestMapTags_pointerReceiverEncoder(t *testing.T) {
+	type target struct {
+		Upper  upper            `json:"upper"`
+		Uppers []upper          `json:"uppers"`
+		ByKey  map[string]upper `json:"by_key"`
+		Labels labelSet         `json:"labels"`
+	}
+	obj := target{
+		Upper:  upper{"a"},
+		Uppers: []upper{{"b"}},
+		ByKey:  map[string]upper{"c": {"c"}},
+	}
+	expected := Mapped{
+		"upper":  "A",
+		"uppers": []interface{}{"B"},
+		"by_key": Mapped{"c": "C"},
+		"labels": nil,
+	}
+	for _, x := range []interface{}{obj, &obj} {
+		mapped, err := MapTagsE(x, "json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(mapped, expected) {
+			t.Errorf("expected %#v, got %#v", expected, mapped)
+		}
+	}
+
+	var filled target
+	if err := FillStructByTags(&filled, Mapped{"labels": "a,b"}, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filled.Labels, labelSet{"a": true, "b": true}) {
+		t.Errorf("expected labels decoded, got %#v", filled.Labels)
+	}
+}
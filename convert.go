@@ -0,0 +1,293 @@
+package smapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	s "strings"
+	"time"
+)
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// isNumeric reports whether the type is one of int*, uint*, float*
+// or json.Number.
+func isNumeric(typ reflect.Type) bool {
+	if typ == jsonNumberType {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func numberError(val reflect.Value, typ reflect.Type, reason string) error {
+	return fmt.Errorf("cannot convert %v (%v) to %v: %s",
+		val.Interface(), val.Type(), typ, reason)
+}
+
+// toBigFloat returns the exact representation of the numeric value.
+func toBigFloat(val reflect.Value) (*big.Float, error) {
+	bf := new(big.Float).SetPrec(0)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return bf.SetInt64(val.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bf.SetUint64(val.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := val.Float()
+		if f != f {
+			return nil, fmt.Errorf("NaN is not a number")
+		}
+		return new(big.Float).SetFloat64(f), nil
+	}
+	if bf, ok := new(big.Float).SetPrec(256).SetString(val.String()); ok {
+		return bf, nil
+	}
+	return nil, fmt.Errorf("invalid number %q", val.String())
+}
+
+/*
+convertNumber converts the numeric value into the numeric type typ.
+The conversion fails when the value overflows the type or when it
+can't be represented exactly, e.g. 1.5 to int or 1<<53+1 to float64.
+Narrowing float64 to float32 only reports overflow, the value is
+rounded just like encoding/json does.
+*/
+func convertNumber(val reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	res := reflect.New(typ).Elem()
+	if typ == jsonNumberType {
+		var str string
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			str = strconv.FormatInt(val.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			str = strconv.FormatUint(val.Uint(), 10)
+		case reflect.Float32:
+			str = strconv.FormatFloat(val.Float(), 'g', -1, 32)
+		case reflect.Float64:
+			str = strconv.FormatFloat(val.Float(), 'g', -1, 64)
+		default:
+			str = val.String()
+		}
+		res.SetString(str)
+		return res, nil
+	}
+	if val.Type() == typ {
+		return val, nil
+	}
+	bf, err := toBigFloat(val)
+	if err != nil {
+		return res, numberError(val, typ, err.Error())
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !bf.IsInt() {
+			return res, numberError(val, typ, "precision loss")
+		}
+		i, acc := bf.Int64()
+		if acc != big.Exact || res.OverflowInt(i) {
+			return res, numberError(val, typ, "overflow")
+		}
+		res.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !bf.IsInt() {
+			return res, numberError(val, typ, "precision loss")
+		}
+		if bf.Sign() < 0 {
+			return res, numberError(val, typ, "negative value")
+		}
+		u, acc := bf.Uint64()
+		if acc != big.Exact || res.OverflowUint(u) {
+			return res, numberError(val, typ, "overflow")
+		}
+		res.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, _ := bf.Float64()
+		if res.OverflowFloat(f) || f-f != 0 {
+			return res, numberError(val, typ, "overflow")
+		}
+		// the decimal text of json.Number is rounded like strconv.ParseFloat
+		isFloat := val.Kind() == reflect.Float32 || val.Kind() == reflect.Float64 ||
+			val.Type() == jsonNumberType
+		if !isFloat && typ.Kind() == reflect.Float32 {
+			if _, acc := bf.Float32(); acc != big.Exact {
+				return res, numberError(val, typ, "precision loss")
+			}
+		} else if !isFloat && big.NewFloat(f).Cmp(bf) != 0 {
+			return res, numberError(val, typ, "precision loss")
+		}
+		res.SetFloat(f)
+	default:
+		return res, numberError(val, typ, "not a number type")
+	}
+	return res, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+/*
+weakValue converts the string into the scalar type typ and the scalar
+into the string type typ. The empty string is converted to zero value.
+It returns false when there's no weak conversion between the types.
+*/
+func weakValue(val reflect.Value, typ reflect.Type) (reflect.Value, bool, error) {
+	res := reflect.New(typ).Elem()
+	if typ.Kind() == reflect.String && typ != jsonNumberType {
+		switch val.Kind() {
+		case reflect.String:
+			res.SetString(val.String())
+		case reflect.Bool:
+			res.SetString(strconv.FormatBool(val.Bool()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if val.Type() == durationType {
+				res.SetString(time.Duration(val.Int()).String())
+			} else {
+				res.SetString(strconv.FormatInt(val.Int(), 10))
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			res.SetString(strconv.FormatUint(val.Uint(), 10))
+		case reflect.Float32, reflect.Float64:
+			res.SetString(strconv.FormatFloat(val.Float(), 'f', -1, val.Type().Bits()))
+		case reflect.Slice:
+			if val.Type().Elem().Kind() != reflect.Uint8 {
+				return res, false, nil
+			}
+			res.SetString(string(val.Bytes()))
+		default:
+			return res, false, nil
+		}
+		return res, true, nil
+	}
+	if val.Kind() != reflect.String {
+		return res, false, nil
+	}
+	str := s.TrimSpace(val.String())
+	if str == "" && (typ.Kind() == reflect.Bool || isNumeric(typ)) {
+		return res, true, nil
+	}
+	var err error
+	switch typ.Kind() {
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(str)
+		res.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typ == durationType {
+			var d time.Duration
+			d, err = time.ParseDuration(str)
+			res.SetInt(int64(d))
+			break
+		}
+		var i int64
+		i, err = strconv.ParseInt(str, 10, typ.Bits())
+		res.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(str, 10, typ.Bits())
+		res.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(str, typ.Bits())
+		res.SetFloat(f)
+	case reflect.String:
+		// json.Number
+		_, err = strconv.ParseFloat(str, 64)
+		res.SetString(str)
+	default:
+		return res, false, nil
+	}
+	if err != nil {
+		return res, true, fmt.Errorf("cannot parse %q as %v: %s", val.String(), typ, err.Error())
+	}
+	return res, true, nil
+}
+
+/*
+scalarValue returns the scalar val as the value of type typ, allocating
+it when typ is a pointer and converting the numbers losslessly.
+The weak conversion between strings and scalars is applied when weak is true.
+*/
+func scalarValue(val reflect.Value, typ reflect.Type, weak bool) (reflect.Value, error) {
+	if val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return reflect.Zero(typ), nil
+	}
+	if typ.Kind() == reflect.Ptr && val.Type() != typ {
+		elem, err := scalarValue(val, typ.Elem(), weak)
+		if err != nil {
+			return elem, err
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+	if val.Type().AssignableTo(typ) {
+		return val, nil
+	}
+	if isNumeric(val.Type()) && isNumeric(typ) {
+		return convertNumber(val, typ)
+	}
+	if typ == durationType && val.Kind() == reflect.String {
+		d, err := time.ParseDuration(s.TrimSpace(val.String()))
+		if err != nil {
+			return val, fmt.Errorf("cannot parse %q as %v: %s", val.String(), typ, err.Error())
+		}
+		return reflect.ValueOf(d), nil
+	}
+	if weak {
+		if res, ok, err := weakValue(val, typ); ok {
+			return res, err
+		}
+	}
+	return val, ErrTypeNotMatch
+}
+
+// isQuotable reports whether the string tag option applies to typ, i.e.
+// the bools and the numbers or the pointers to them without their own
+// encoding, just like encoding/json.
+func isQuotable(f field) bool {
+	if f.caps.encoder || f.caps.decoder || f.caps.textMarshaler || f.caps.jsonMarshaler {
+		return false
+	}
+	typ := f.typ
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Bool || typ != jsonNumberType && isNumeric(typ)
+}
+
+// quotedValue returns the field value encoded in the string for the
+// string tag option just like encoding/json, e.g. `json:"n,string"` maps
+// 5 to "5". It's not ok when the option doesn't apply to the field.
+func quotedValue(f field, fieldval reflect.Value) (interface{}, bool) {
+	if !f.opts.Contains("string") || !isQuotable(f) {
+		return nil, false
+	}
+	if isValueNil(fieldval) {
+		return nil, true
+	}
+	data, err := json.Marshal(reflect.Indirect(fieldval).Interface())
+	if err != nil {
+		return nil, false
+	}
+	return string(data), true
+}
+
+// unquotedValue decodes the string val encoded for the string tag option
+// into the value of typ just like encoding/json.
+func unquotedValue(val reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	ptr := reflect.New(typ)
+	if err := json.Unmarshal([]byte(val.String()), ptr.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid use of ,string option: %s", err.Error())
+	}
+	return ptr.Elem(), nil
+}
@@ -0,0 +1,166 @@
+package smapping
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFillStructByTags_numericFromJSON(t *testing.T) {
+	type numbers struct {
+		Int     int         `json:"int"`
+		Int8    int8        `json:"int8"`
+		Uint16  uint16      `json:"uint16"`
+		Float32 float32     `json:"float32"`
+		PtrInt  *int64      `json:"ptr_int"`
+		Number  json.Number `json:"number"`
+		Ints    []int       `json:"ints"`
+		PtrUint []*uint     `json:"ptr_uints"`
+	}
+	raw := []byte(`{
+		"int": 42,
+		"int8": -8,
+		"uint16": 65535,
+		"float32": 1.5,
+		"ptr_int": 9007199254740992,
+		"number": 12,
+		"ints": [1, 2, 3],
+		"ptr_uints": [4, null, 5]
+	}`)
+	var m Mapped
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	var obj numbers
+	if err := FillStructByTags(&obj, m, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if obj.Int != 42 || obj.Int8 != -8 || obj.Uint16 != math.MaxUint16 ||
+		obj.Float32 != 1.5 || obj.Number != "12" {
+		t.Errorf("wrong converted values %#v", obj)
+	}
+	if obj.PtrInt == nil || *obj.PtrInt != 1<<53 {
+		t.Errorf("wrong pointer value %v", obj.PtrInt)
+	}
+	if len(obj.Ints) != 3 || obj.Ints[2] != 3 {
+		t.Errorf("wrong slice value %v", obj.Ints)
+	}
+	if len(obj.PtrUint) != 3 || *obj.PtrUint[0] != 4 || obj.PtrUint[1] != nil {
+		t.Errorf("wrong pointer slice value %v", obj.PtrUint)
+	}
+
+	var number numbers
+	err := FillStructByTags(&number, Mapped{"int": json.Number("7")}, "json")
+	if err != nil || number.Int != 7 {
+		t.Errorf("expected 7 from json.Number, got %d: %v", number.Int, err)
+	}
+}
+
+func TestFillStruct_numericLoss(t *testing.T) {
+	type numbers struct {
+		Int8    int8
+		Uint    uint
+		Int     int
+		Float64 float64
+	}
+	cases := []struct {
+		mapped Mapped
+		reason string
+	}{
+		{Mapped{"Int8": 300}, "overflow"},
+		{Mapped{"Uint": -1}, "negative value"},
+		{Mapped{"Int": 1.5}, "precision loss"},
+		{Mapped{"Int": math.Inf(1)}, "precision loss"},
+		{Mapped{"Float64": int64(1<<53 + 1)}, "precision loss"},
+		{Mapped{"Int": json.Number("12a")}, "invalid number"},
+	}
+	for _, c := range cases {
+		var obj numbers
+		err := FillStruct(&obj, c.mapped)
+		if err == nil {
+			t.Errorf("expected error for %v", c.mapped)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.reason) {
+			t.Errorf("expected %q in error, got %s", c.reason, err)
+		}
+	}
+}
+
+func TestFillStructWeak(t *testing.T) {
+	type config struct {
+		Port     int           `env:"PORT"`
+		Ratio    float32       `env:"RATIO"`
+		Debug    bool          `env:"DEBUG"`
+		Timeout  time.Duration `env:"TIMEOUT"`
+		Started  time.Time     `env:"STARTED"`
+		Name     string        `env:"NAME"`
+		Retries  *uint8        `env:"RETRIES"`
+		Empty    int           `env:"EMPTY"`
+		Versions []int         `env:"VERSIONS"`
+	}
+	mapped := Mapped{
+		"PORT":     "8080",
+		"RATIO":    "0.5",
+		"DEBUG":    "true",
+		"TIMEOUT":  "1h30m",
+		"STARTED":  "2000-01-01T00:00:00Z",
+		"NAME":     42,
+		"RETRIES":  "3",
+		"EMPTY":    "",
+		"VERSIONS": []interface{}{"1", 2, "3"},
+	}
+	var cfg config
+	if err := FillStructByTagsWeak(&cfg, mapped, "env"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 8080 || cfg.Ratio != 0.5 || !cfg.Debug ||
+		cfg.Timeout != 90*time.Minute || !cfg.Started.Equal(toki) ||
+		cfg.Name != "42" || cfg.Retries == nil || *cfg.Retries != 3 ||
+		len(cfg.Versions) != 3 || cfg.Versions[2] != 3 {
+		t.Errorf("wrong weakly typed values %#v", cfg)
+	}
+
+	cfg = config{}
+	if err := FillStructByTags(&cfg, Mapped{"PORT": "8080"}, "env"); err == nil {
+		t.Errorf("expected error without weak typing")
+	}
+
+	cfg = config{}
+	err := FillStructByTagsWeak(&cfg, Mapped{
+		"PORT":    "eighty",
+		"DEBUG":   "maybe",
+		"RETRIES": "300",
+		"NAME":    "still filled",
+	}, "env")
+	if err == nil {
+		t.Fatal("expected parse errors")
+	}
+	for _, field := range []string{"PORT", "DEBUG", "RETRIES"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected %s reported in %s", field, err)
+		}
+	}
+	if cfg.Name != "still filled" {
+		t.Errorf("other fields should still be filled, got %#v", cfg)
+	}
+
+	var sink struct {
+		Label string
+		Count int
+		Total uint
+	}
+	// the leading zeros are decimal as in the query strings and CSV
+	err = FillStructWeak(&sink, Mapped{"Label": true, "Count": "010", "Total": "08"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.Label != "true" || sink.Count != 10 || sink.Total != 8 {
+		t.Errorf("wrong weakly typed values %#v", sink)
+	}
+	if err := FillStructWeak(&sink, Mapped{"Count": "0x10"}); err == nil {
+		t.Errorf("expected the hexadecimal rejected, got %d", sink.Count)
+	}
+}
@@ -0,0 +1,288 @@
+package smapping
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	s "strings"
+)
+
+// convKind is how the value of a field pair is converted.
+type convKind int
+
+const (
+	// convAssign assigns the value as is.
+	convAssign convKind = iota
+	// convScalar converts the scalar value like FillStruct does.
+	convScalar
+	// convStruct converts the nested struct or pointer to struct.
+	convStruct
+	// convSlice converts the slice of nested structs.
+	convSlice
+	// convMapped goes through MapTags and FillStruct representation,
+	// e.g. for MapEncoder, MapDecoder or DecodeHook.
+	convMapped
+)
+
+// fieldPair is the source field matched with the destination field.
+type fieldPair struct {
+	src, dst field
+	kind     convKind
+}
+
+type convKey struct {
+	dst, src reflect.Type
+	tag      string
+}
+
+/*
+Converter copies the fields of a source struct type into a destination
+struct type directly without the intermediate Mapped. The fields are
+matched by the field names or tag values just like MapTags followed by
+FillStructByTags with the same nested struct, slice, pointer and time
+handling. The Converter is compiled once for its pair of types and safe
+for concurrent use.
+*/
+type Converter struct {
+	mapper   *Mapper
+	tag      string
+	dst, src reflect.Type
+	pairs    []fieldPair
+}
+
+// structType returns the struct type of x which is either a struct,
+// a pointer to struct or a reflect.Type of them.
+func structType(x interface{}) (reflect.Type, error) {
+	typ, ok := x.(reflect.Type)
+	if !ok {
+		typ = reflect.TypeOf(x)
+	}
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("smapping: %v is not a struct", typ)
+	}
+	return typ, nil
+}
+
+/*
+NewConverter compiles the Converter from the type of src to the type of
+dst matched with the tag, the empty tag matches the field names.
+Both of dst and src can be the struct, the pointer to struct or
+its reflect.Type e.g. NewConverter((*Model)(nil), DTO{}, "json").
+*/
+func NewConverter(dst, src interface{}, tag string) (*Converter, error) {
+	return defaultMapper.newConverter(dst, src, tag)
+}
+
+// NewConverter compiles the Converter just like the package level
+// NewConverter with the configured tags.
+func (m *Mapper) NewConverter(dst, src interface{}) (*Converter, error) {
+	return m.newConverter(dst, src, m.config.TagName)
+}
+
+func (m *Mapper) newConverter(dst, src interface{}, tag string) (*Converter, error) {
+	dtyp, err := structType(dst)
+	if err != nil {
+		return nil, err
+	}
+	styp, err := structType(src)
+	if err != nil {
+		return nil, err
+	}
+	return m.converter(dtyp, styp, tag), nil
+}
+
+// converter returns the cached Converter of the struct types.
+func (m *Mapper) converter(dst, src reflect.Type, tag string) *Converter {
+	key := convKey{dst: dst, src: src, tag: tag}
+	if c, ok := m.converters.Load(key); ok {
+		return c.(*Converter)
+	}
+	c := &Converter{mapper: m, tag: tag, dst: dst, src: src}
+	dstFields := m.plan(dst, tag).byKey
+	for _, sf := range m.plan(src, tag).fields {
+		df, ok := dstFields[sf.name]
+		if !ok && m.config.CaseInsensitive {
+			df, ok = dstFields[s.ToLower(sf.name)]
+		}
+		if !ok {
+			continue
+		}
+		c.pairs = append(c.pairs, fieldPair{src: sf, dst: df,
+			kind: m.convKindOf(df.typ, sf.typ)})
+	}
+	actual, _ := m.converters.LoadOrStore(key, c)
+	return actual.(*Converter)
+}
+
+func isScalarKind(typ reflect.Type) bool {
+	return typ.Kind() < reflect.Array || typ.Kind() == reflect.String
+}
+
+// nestedStruct reports whether the struct or pointer to struct type
+// is converted field by field.
+func nestedStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || isOpaque(typ) {
+		return false
+	}
+	caps := capsOf(typ)
+	return !caps.textMarshaler && !caps.textUnmarshaler
+}
+
+func (m *Mapper) convKindOf(dst, src reflect.Type) convKind {
+	dcaps, scaps := capsOf(dst), capsOf(src)
+	switch {
+	case m.config.DecodeHook != nil, scaps.encoder, dcaps.decoder:
+		return convMapped
+	case m.hasRegistered(dst, src):
+		return convMapped
+	case dst == src && dst.Kind() != reflect.Ptr && dcaps.textUnmarshaler:
+		return convAssign
+	case scaps.textMarshaler, dcaps.textUnmarshaler,
+		m.config.JSONCodec && (scaps.jsonMarshaler || dcaps.jsonUnmarshaler):
+		return convMapped
+	case dst == src && (isScalarKind(dst) || dcaps.isTime && dst.Kind() != reflect.Ptr):
+		return convAssign
+	case isScalarKind(dst) && isScalarKind(src):
+		return convScalar
+	case nestedStruct(dst) && nestedStruct(src):
+		return convStruct
+	case dst.Kind() == reflect.Slice && src.Kind() == reflect.Slice &&
+		nestedStruct(dst.Elem()) && nestedStruct(src.Elem()) &&
+		!m.hasRegistered(dst.Elem(), src.Elem()):
+		return convSlice
+	}
+	return convMapped
+}
+
+// Convert copies the fields of src into dst. The dst must be a pointer
+// to the destination struct and src the source struct or pointer to it.
+// The failing fields are reported as *MultiError of *FieldError.
+func (c *Converter) Convert(dst, src interface{}) error {
+	dval := reflect.ValueOf(dst)
+	if dval.Kind() != reflect.Ptr || dval.IsNil() || dval.Elem().Type() != c.dst {
+		return fmt.Errorf("smapping: converter destination must be *%v, got %T", c.dst, dst)
+	}
+	sval := reflect.ValueOf(src)
+	for sval.Kind() == reflect.Ptr && !sval.IsNil() {
+		sval = sval.Elem()
+	}
+	if !sval.IsValid() || sval.Type() != c.src {
+		return fmt.Errorf("smapping: converter source must be %v, got %T", c.src, src)
+	}
+	return c.convert(dval.Elem(), sval)
+}
+
+func (c *Converter) convert(dst, src reflect.Value) error {
+	m := c.mapper
+	errs := &MultiError{}
+	for _, p := range c.pairs {
+		srcval := fieldByIndex(src, p.src.index)
+		if !srcval.IsValid() || isValueNil(srcval) || p.src.opts.omits(srcval) {
+			continue
+		}
+		val, ok, err := c.pairValue(p, srcval)
+		if err != nil {
+			if m.config.ErrorMode == ErrorIgnore {
+				continue
+			}
+			errs.add(p.src.name, err)
+			if m.config.ErrorMode == ErrorFailFast {
+				break
+			}
+			continue
+		}
+		if ok {
+			fieldByIndexAlloc(dst, p.dst.index).Set(val)
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// pairValue converts the source field value to the destination field type.
+func (c *Converter) pairValue(p fieldPair, srcval reflect.Value) (reflect.Value, bool, error) {
+	m := c.mapper
+	switch p.kind {
+	case convAssign:
+		return srcval, true, nil
+	case convScalar:
+		val, err := scalarValue(srcval, p.dst.typ, m.config.WeaklyTyped)
+		if err != nil {
+			return val, false, fieldError(p.dst, c.tag, p.src.name, srcval.Interface(), err)
+		}
+		return val, true, nil
+	case convStruct:
+		val, err := c.structValue(p.dst.typ, srcval)
+		return val, err == nil, err
+	case convSlice:
+		res := reflect.MakeSlice(p.dst.typ, 0, srcval.Len())
+		errs := &MultiError{}
+		for i := 0; i < srcval.Len(); i++ {
+			elem := srcval.Index(i)
+			if isValueNil(elem) {
+				res = reflect.Append(res, reflect.Zero(p.dst.typ.Elem()))
+				continue
+			}
+			val, err := c.structValue(p.dst.typ.Elem(), elem)
+			if err != nil {
+				errs.add("["+strconv.Itoa(i)+"]", err)
+				if m.config.ErrorMode == ErrorFailFast {
+					return res, false, errs
+				}
+			}
+			res = reflect.Append(res, val)
+		}
+		return res, len(errs.Errors) == 0, errs.errorOrNil()
+	}
+	value, err := m.getValTagE(srcval, c.tag)
+	if err != nil {
+		return reflect.Value{}, false, encodeError(srcval, c.tag, p.src.name, err)
+	}
+	return m.fieldValue(p.dst, c.tag, p.src.name, value)
+}
+
+// structValue converts the nested struct srcval into the new value of
+// the struct or pointer to struct type typ.
+func (c *Converter) structValue(typ reflect.Type, srcval reflect.Value) (reflect.Value, error) {
+	srcval = reflect.Indirect(srcval)
+	elem := typ
+	if typ.Kind() == reflect.Ptr {
+		elem = typ.Elem()
+	}
+	res := reflect.New(elem)
+	err := c.mapper.converter(elem, srcval.Type(), c.tag).convert(res.Elem(), srcval)
+	if typ.Kind() == reflect.Ptr {
+		return res, err
+	}
+	return res.Elem(), err
+}
+
+/*
+Convert copies the fields of src into dst matched by the tag without the
+intermediate Mapped, the empty tag matches the field names. It's the
+equivalent of MapTags(src, tag) followed by FillStructByTags(dst, mapped, tag)
+with the compiled Converter of both types cached. The dst must be
+a pointer to struct.
+*/
+func Convert(dst, src interface{}, tag string) error {
+	return defaultMapper.convert(dst, src, tag)
+}
+
+// Convert copies the fields of src into dst just like the package level
+// Convert with the configured tags.
+func (m *Mapper) Convert(dst, src interface{}) error {
+	return m.convert(dst, src, m.config.TagName)
+}
+
+func (m *Mapper) convert(dst, src interface{}, tag string) error {
+	c, err := m.newConverter(dst, src, tag)
+	if err != nil {
+		return err
+	}
+	return c.Convert(dst, src)
+}
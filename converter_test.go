@@ -0,0 +1,113 @@
+package smapping
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type (
+	convSourceDTO struct {
+		Label   string     `json:"label"`
+		Version int        `json:"version"`
+		Toki    time.Time  `json:"tomare"`
+		Addr    *string    `json:"address"`
+		Embed   *embedObj  `json:"embed"`
+		Embeds  []embedObj `json:"embeds"`
+		Tags    []string   `json:"tags"`
+	}
+	convSinkModel struct {
+		Label   string      `json:"label"`
+		Version int64       `json:"version"`
+		Toki    time.Time   `json:"tomare"`
+		Addr    *string     `json:"address"`
+		Embed   embedObj    `json:"embed"`
+		Embeds  []*embedObj `json:"embeds"`
+		Tags    []string    `json:"tags"`
+	}
+)
+
+var convSource = convSourceDTO{
+	Label:   "source",
+	Version: 2,
+	Toki:    toki,
+	Addr:    &hello,
+	Embed:   &embedObj{1, "one", 1.1},
+	Embeds:  []embedObj{{2, "two", 2.2}, {3, "three", 3.3}},
+	Tags:    []string{"a", "b"},
+}
+
+func TestConvert(t *testing.T) {
+	var converted convSinkModel
+	if err := Convert(&converted, &convSource, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var filled convSinkModel
+	if err := FillStructByTags(&filled, MapTags(&convSource, "json"), "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(converted, filled) {
+		t.Errorf("expected %#v, got %#v", filled, converted)
+	}
+	if converted.Embed.FieldStr != "one" || len(converted.Embeds) != 2 ||
+		converted.Embeds[1].FieldInt != 3 || *converted.Addr != hello {
+		t.Errorf("wrong converted value %#v", converted)
+	}
+
+	var named sink
+	if err := Convert(&named, sourceobj, ""); err != nil {
+		t.Fatal(err)
+	}
+	if named.Label != sourceobj.Label || named.Info != sourceobj.Info {
+		t.Errorf("wrong converted value %#v", named)
+	}
+}
+
+func TestConverter(t *testing.T) {
+	conv, err := NewConverter((*embedEmbed)(nil), embedEmbed{}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := embedEmbed{Embed2: &embedObj{FieldInt: 2}}
+	var dst embedEmbed
+	if err := conv.Convert(&dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Embed2 == nil || dst.Embed2 == src.Embed2 || dst.Embed2.FieldInt != 2 {
+		t.Errorf("expected the copy of Embed2, got %#v", dst.Embed2)
+	}
+	if err := conv.Convert(dst, src); err == nil {
+		t.Errorf("expected error of non pointer destination")
+	}
+	if err := conv.Convert(&sink{}, src); err == nil {
+		t.Errorf("expected error of different destination type")
+	}
+
+	type floats struct {
+		FieldFloat float64 `json:"fieldInt"`
+	}
+	err = Convert(&embedObjs{}, struct {
+		Objs []floats `json:"embeds"`
+	}{Objs: []floats{{1}, {1.5}}}, "json")
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "embeds[1].fieldInt" {
+		t.Errorf("expected error at embeds[1].fieldInt, got %v", err)
+	}
+}
+
+func BenchmarkConvert(b *testing.B) {
+	var dst convSinkModel
+	for i := 0; i < b.N; i++ {
+		Convert(&dst, &convSource, "json")
+	}
+	_ = dst
+}
+
+func BenchmarkMapTagsFillStructByTags(b *testing.B) {
+	var dst convSinkModel
+	for i := 0; i < b.N; i++ {
+		FillStructByTags(&dst, MapTags(&convSource, "json"), "json")
+	}
+	_ = dst
+}
@@ -0,0 +1,118 @@
+package smapping
+
+import (
+	"reflect"
+	s "strings"
+)
+
+// hasDefaults reports whether the struct typ has the fields with the
+// default tag at any depth, the types in seen are skipped.
+func hasDefaults(typ reflect.Type, tags []string, seen map[reflect.Type]bool) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+	for _, f := range typeFields(typ, tags...) {
+		if f.hasDef || nestedStruct(f.typ) && hasDefaults(f.typ, tags, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+fillDefaults sets the default values of the zero fields of sval not in
+present and recurses into the nested structs. The nil pointers are
+allocated unless their type is in allocs, i.e. it's being allocated
+by the outer struct already.
+*/
+func (m *Mapper) fillDefaults(sval reflect.Value, plan *structPlan, tagname string,
+	present map[string]bool, allocs map[reflect.Type]bool) error {
+	errs := &MultiError{}
+	for _, f := range plan.defaults {
+		if present[f.name] {
+			continue
+		}
+		// the values already set by the caller are kept
+		if fv := fieldByIndex(sval, f.index); fv.IsValid() && !fv.IsZero() {
+			continue
+		}
+		val, err := m.defaultValue(f, f.def)
+		if err != nil {
+			errs.add(f.name, fieldError(f, tagname, f.name, f.def, err))
+			continue
+		}
+		fieldByIndexAlloc(sval, f.index).Set(val)
+	}
+	for _, f := range plan.nested {
+		if present[f.name] {
+			continue
+		}
+		fv := fieldByIndexAlloc(sval, f.index)
+		nested := fv
+		alloc := fv.Kind() == reflect.Ptr && fv.IsNil()
+		if alloc {
+			if allocs[f.typ.Elem()] {
+				continue
+			}
+			if allocs == nil {
+				allocs = map[reflect.Type]bool{}
+			}
+			allocs[f.typ.Elem()] = true
+			nested = reflect.New(f.typ.Elem()).Elem()
+		} else if fv.Kind() == reflect.Ptr {
+			nested = fv.Elem()
+		}
+		err := m.fillDefaults(nested, m.plan(nested.Type(), tagname), tagname, nil, allocs)
+		if alloc {
+			delete(allocs, f.typ.Elem())
+			fv.Set(nested.Addr())
+		}
+		if err != nil {
+			errs.add(f.name, err)
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// defaultValue converts the default tag value def into the type of f.
+func (m *Mapper) defaultValue(f field, def string) (reflect.Value, error) {
+	val := reflect.ValueOf(def)
+	typ := f.typ
+	switch {
+	case f.caps.isTime:
+		err := m.fillTime(f, &val)
+		return val, err
+	case f.caps.textUnmarshaler:
+		return textValue(typ, val)
+	case typ.Kind() == reflect.Ptr:
+		elem := typeField(typ.Elem())
+		elem.opts = f.opts
+		ev, err := m.defaultValue(elem, def)
+		if err != nil {
+			return ev, err
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(ev)
+		return ptr, nil
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8:
+		res := reflect.MakeSlice(typ, 0, 0)
+		if s.TrimSpace(def) == "" {
+			return res, nil
+		}
+		elem := typeField(typ.Elem())
+		elem.opts = f.opts
+		for _, part := range s.Split(def, ",") {
+			ev, err := m.defaultValue(elem, s.TrimSpace(part))
+			if err != nil {
+				return ev, err
+			}
+			res = reflect.Append(res, ev)
+		}
+		return res, nil
+	}
+	return scalarValue(val, typ, true)
+}
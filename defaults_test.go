@@ -0,0 +1,79 @@
+package smapping
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type defaultsLimits struct {
+	Burst int     `json:"burst" default:"10"`
+	Rate  float64 `json:"rate" default:"1.5"`
+}
+
+type defaultsNode struct {
+	Name string        `json:"name" default:"leaf"`
+	Next *defaultsNode `json:"next"`
+}
+
+type defaultsConfig struct {
+	Host    string          `json:"host" default:"localhost"`
+	Port    *int            `json:"port" default:"8080"`
+	Debug   bool            `json:"debug" default:"true"`
+	Tags    []string        `json:"tags" default:"a, b"`
+	Ports   []uint16        `json:"ports" default:"80,443"`
+	Timeout time.Duration   `json:"timeout" default:"1m30s"`
+	Since   time.Time       `json:"since,layout=DateOnly" default:"2021-03-04"`
+	Limits  defaultsLimits  `json:"limits"`
+	Backup  *defaultsLimits `json:"backup"`
+	Node    defaultsNode    `json:"node"`
+	Plain   string          `json:"plain"`
+}
+
+func TestFillStruct_defaults(t *testing.T) {
+	var cfg defaultsConfig
+	err := FillStructByTags(&cfg, Mapped{
+		"host":   nil,
+		"debug":  false,
+		"limits": Mapped{"rate": 2.5},
+	}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := 8080
+	expected := defaultsConfig{
+		Host:    "localhost",
+		Port:    &port,
+		Tags:    []string{"a", "b"},
+		Ports:   []uint16{80, 443},
+		Timeout: 90 * time.Second,
+		Since:   time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		Limits:  defaultsLimits{Burst: 10, Rate: 2.5},
+		Backup:  &defaultsLimits{Burst: 10, Rate: 1.5},
+		Node:    defaultsNode{Name: "leaf"},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected %#v, got %#v", expected, cfg)
+	}
+
+	// the values already set are kept
+	port = 9090
+	cfg = defaultsConfig{Port: &port, Host: "example.com",
+		Limits: defaultsLimits{Burst: 3}}
+	if err := FillStructByTags(&cfg, Mapped{}, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if *cfg.Port != 9090 || cfg.Host != "example.com" || cfg.Limits.Burst != 3 ||
+		cfg.Limits.Rate != 1.5 || len(cfg.Tags) != 2 {
+		t.Errorf("expected the set values kept, got %#v", cfg)
+	}
+
+	var limits struct {
+		Burst int `default:"many"`
+	}
+	err = FillStruct(&limits, Mapped{})
+	fe, ok := err.(*MultiError)
+	if !ok || len(fe.Errors) != 1 || fe.Errors[0].Path != "Burst" {
+		t.Errorf("expected the error of Burst default, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package smapping
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	s "strings"
+)
+
+// ErrTypeNotMatch is the cause of FieldError when the provided value
+// can't be converted to the field type.
+var ErrTypeNotMatch = errors.New("type not match")
+
+// ErrUnusedKey is the cause of FieldError when the key of Mapped has no
+// matching field, see Config.ErrorUnused.
+var ErrUnusedKey = errors.New("unused key")
+
+// ErrRequired is the cause of FieldError when the field with the required
+// tag option has no value, e.g. `json:"name,required"`.
+var ErrRequired = errors.New("required field is missing")
+
+// ErrUnsupportedType is the cause of FieldError when the value can't be
+// mapped such as channels, funcs and unsafe pointers.
+var ErrUnsupportedType = errors.New("unsupported type")
+
+/*
+FieldError is the error of filling a single field. The Path is the full
+path of the field key from the top level Mapped, the nested keys are
+separated by dot and the slice indices are put in brackets, for example
+"embed2.fieldInt" or "embeds[3].fieldInt".
+*/
+type FieldError struct {
+	// Path is the full path of the key.
+	Path string
+	// Key is the key of the field in its own Mapped.
+	Key string
+	// Tag is the tag name used to match the key, empty when the key
+	// is matched with the field name.
+	Tag string
+	// Type is the expected type of the field.
+	Type reflect.Type
+	// Value is the provided value.
+	Value interface{}
+	// Err is the cause of the failure.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	if e.Err == ErrTypeNotMatch {
+		return fmt.Sprintf("provided value (%#v) type %T not match field tag '%s' of tagname '%s' of type '%v' from object",
+			e.Value, e.Value, e.Path, e.Tag, e.Type)
+	}
+	return fmt.Sprintf("field tag '%s' of tagname '%s': %s", e.Path, e.Tag, e.Err)
+}
+
+// Unwrap returns the cause of the field error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+/*
+MultiError collects the FieldError of all failing fields. It's the error
+returned by FillStruct and its family and it can be inspected with
+errors.As to get either the MultiError itself or the first FieldError.
+*/
+type MultiError struct {
+	Errors []*FieldError
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return s.Join(msgs, ", ")
+}
+
+// Unwrap returns the field errors.
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Is reports whether any of the field errors matches target.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first field error that matches target.
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends err to the collected errors, the paths of the nested
+// errors are prefixed with the key.
+func (e *MultiError) add(key string, err error) {
+	var nested *MultiError
+	if errors.As(err, &nested) && nested != e {
+		for _, fe := range nested.Errors {
+			fe.Path = joinPath(key, fe.Path)
+			e.Errors = append(e.Errors, fe)
+		}
+		return
+	}
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		fe.Path = joinPath(key, fe.Path)
+		e.Errors = append(e.Errors, fe)
+		return
+	}
+	e.Errors = append(e.Errors, &FieldError{Path: key, Key: key, Err: err})
+}
+
+// errorOrNil returns nil when there's no collected error.
+func (e *MultiError) errorOrNil() error {
+	if e == nil || len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+func joinPath(parent, child string) string {
+	switch {
+	case parent == "":
+		return child
+	case child == "":
+		return parent
+	case s.HasPrefix(child, "["):
+		return parent + child
+	}
+	return parent + "." + child
+}
@@ -0,0 +1,191 @@
+package smapping
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFieldError_paths(t *testing.T) {
+	mapped := Mapped{
+		"embed1": Mapped{"fieldInt": 1, "fieldStr": 2},
+		"embed2": Mapped{"fieldInt": "not int"},
+	}
+	var obj embedEmbed
+	err := FillStructByTags(&obj, mapped, "json")
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("expected *MultiError, got %T %v", err, err)
+	}
+	paths := []string{}
+	for _, fe := range merr.Errors {
+		paths = append(paths, fe.Path)
+	}
+	sort.Strings(paths)
+	expected := []string{"embed1.fieldStr", "embed2.fieldInt"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected paths %v, got %v", expected, paths)
+	}
+	for _, fe := range merr.Errors {
+		if fe.Tag != "json" || fe.Err != ErrTypeNotMatch {
+			t.Errorf("wrong field error %#v", fe)
+		}
+		if fe.Path == "embed2.fieldInt" &&
+			(fe.Key != "fieldInt" || fe.Value != "not int" || fe.Type != reflect.TypeOf(0)) {
+			t.Errorf("wrong field error %#v", fe)
+		}
+	}
+	if !errors.Is(err, ErrTypeNotMatch) {
+		t.Errorf("expected errors.Is ErrTypeNotMatch")
+	}
+
+	var objs embedObjs
+	err = FillStructByTags(&objs, Mapped{
+		"embeds": []interface{}{
+			Mapped{"fieldInt": 1},
+			Mapped{"fieldInt": 1.5},
+		},
+	}, "json")
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FieldError, got %T %v", err, err)
+	}
+	if fe.Path != "embeds[1].fieldInt" {
+		t.Errorf("expected path embeds[1].fieldInt, got %s", fe.Path)
+	}
+}
+
+func TestFieldError_cause(t *testing.T) {
+	cause := errors.New("decode failure")
+	mapper := NewMapper(Config{
+		DecodeHook: func(from, to reflect.Type, data interface{}) (interface{}, error) {
+			return nil, cause
+		},
+	})
+	var obj sink
+	err := mapper.FillStruct(&obj, Mapped{"Label": "label"})
+	if !errors.Is(err, cause) {
+		t.Errorf("expected the cause wrapped, got %v", err)
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "Label" || fe.Tag != "" {
+		t.Errorf("wrong field error %#v", fe)
+	}
+}
+
+type failingEncoder struct{}
+
+func (failingEncoder) MapEncode() (interface{}, error) {
+	return nil, errors.New("encode failure")
+}
+
+func TestMapTagsE(t *testing.T) {
+	type nested struct {
+		Enc failingEncoder `json:"enc"`
+		OK  int            `json:"ok"`
+	}
+	type target struct {
+		Nested nested                    `json:"nested"`
+		List   []failingEncoder          `json:"list"`
+		ByKey  map[string]failingEncoder `json:"by_key"`
+		Ch     chan int                  `json:"ch"`
+		Fn     func()                    `json:"fn"`
+		Label  string                    `json:"label"`
+	}
+	obj := target{
+		List:  []failingEncoder{{}},
+		ByKey: map[string]failingEncoder{"x": {}},
+		Ch:    make(chan int),
+		Fn:    func() {},
+		Label: "label",
+	}
+	mapped, err := MapTagsE(&obj, "json")
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("expected *MultiError, got %T %v", err, err)
+	}
+	paths := []string{}
+	for _, fe := range merr.Errors {
+		paths = append(paths, fe.Path)
+	}
+	sort.Strings(paths)
+	expected := []string{"by_key.x", "ch", "fn", "list[0]", "nested.enc"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected paths %v, got %v", expected, paths)
+	}
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected errors.Is ErrUnsupportedType")
+	}
+	if mapped["label"] != "label" || mapped["nested"].(Mapped)["ok"] != 0 ||
+		mapped["nested"].(Mapped)["enc"] != nil {
+		t.Errorf("expected the rest mapped, got %#v", mapped)
+	}
+
+	if _, err := MapFieldsE(sourceobj); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if mapped := MapTags(&obj, "json"); mapped["ch"] != obj.Ch {
+		t.Errorf("expected MapTags to copy the channel, got %#v", mapped["ch"])
+	}
+}
+
+func TestMapper_strict(t *testing.T) {
+	type inner struct {
+		Name  string `json:"name,required"`
+		Value int    `json:"value"`
+	}
+	type config struct {
+		Host  string  `json:"host,required"`
+		Port  int     `json:"port"`
+		Inner inner   `json:"inner"`
+		Items []inner `json:"items"`
+	}
+	mapped := Mapped{
+		"hots":  "localhost",
+		"port":  80,
+		"inner": Mapped{"value": 1, "nmae": "typo"},
+		"items": []interface{}{Mapped{"name": "ok"}, Mapped{"name": nil}},
+	}
+	collect := func(err error) []string {
+		var merr *MultiError
+		if !errors.As(err, &merr) {
+			t.Fatalf("expected *MultiError, got %T %v", err, err)
+		}
+		paths := []string{}
+		for _, fe := range merr.Errors {
+			paths = append(paths, fe.Path)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	var cfg config
+	err := FillStructByTags(&cfg, mapped, "json")
+	expected := []string{"host", "inner.name", "items[1].name"}
+	if paths := collect(err); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected paths %v, got %v", expected, paths)
+	}
+	if !errors.Is(err, ErrRequired) || errors.Is(err, ErrUnusedKey) {
+		t.Errorf("expected only required errors, got %v", err)
+	}
+	if cfg.Port != 80 {
+		t.Errorf("expected the rest filled, got %#v", cfg)
+	}
+
+	cfg = config{}
+	err = NewMapper(Config{TagName: "json", ErrorUnused: true}).FillStruct(&cfg, mapped)
+	expected = []string{"host", "hots", "inner.name", "inner.nmae", "items[1].name"}
+	if paths := collect(err); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected paths %v, got %v", expected, paths)
+	}
+	if !errors.Is(err, ErrUnusedKey) {
+		t.Errorf("expected unused key errors, got %v", err)
+	}
+
+	err = NewMapper(Config{TagName: "json", ErrorUnused: true}).
+		FillStructDeflate(&cfg, Mapped{"host": "localhost", "name": "n", "value": 2})
+	if err != nil {
+		t.Errorf("expected the flat keys used, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package smapping
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sort"
+)
+
+// field is the exported struct field resolved for the tag together with
+// the fields promoted from the embedded structs.
+type field struct {
+	name   string
+	tag    string
+	opts   tagOptions
+	tagged bool
+	index  []int
+	typ    reflect.Type
+	// def is the value of the default tag when hasDef.
+	def    string
+	hasDef bool
+	// rules is the value of the validate tag.
+	rules string
+	// out is the key of Mapped produced by MapTags, it's only set for
+	// the fields of structPlan.
+	out string
+	// caps is only set for the fields of structPlan.
+	caps *typeCaps
+}
+
+var (
+	scannerI = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerI  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isOpaque reports whether the embedded type should be kept as
+// a single value instead of promoting its fields, this is the case
+// for time.Time and the types having their own conversion.
+func isOpaque(typ reflect.Type) bool {
+	if isTime(typ) {
+		return true
+	}
+	for _, iface := range []reflect.Type{mapEncoderI, mapDecoderI, scannerI, valuerI} {
+		if typ.Implements(iface) || reflect.PtrTo(typ).Implements(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupTags returns the first of tags found in the field tag.
+func lookupTags(sf reflect.StructField, tags []string) (string, string, bool) {
+	for _, tag := range tags {
+		if tag == "" {
+			return tag, "", false
+		}
+		if tagvalue, ok := sf.Tag.Lookup(tag); ok {
+			return tag, tagvalue, true
+		}
+	}
+	return "", "", false
+}
+
+/*
+typeFields returns the fields that should be recognized for the
+tags, the first tag found in the field decides its key and the
+empty tag means the field names are used. The fields of embedded
+structs without tag name are promoted following the rules of
+encoding/json, the shallowest field wins and the conflicting fields
+at the same depth annihilate each other unless only one of them is tagged.
+*/
+func typeFields(t reflect.Type, tags ...string) []field {
+	if len(tags) == 0 {
+		tags = []string{""}
+	}
+	current := []field{}
+	next := []field{{typ: t}}
+	var count, nextCount map[reflect.Type]int
+	visited := map[reflect.Type]bool{}
+	var fields []field
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous {
+					if sf.PkgPath != "" && (ft.Kind() != reflect.Struct ||
+						sf.Type.Kind() == reflect.Ptr) {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					continue
+				}
+				tag, tagvalue, found := lookupTags(sf, tags)
+				if tagvalue == "-" {
+					continue
+				}
+				name, opts := parseTag(tagvalue)
+				tagged := name != ""
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct &&
+					!isOpaque(ft) {
+					nextCount[ft]++
+					if nextCount[ft] == 1 {
+						next = append(next, field{
+							name:  ft.Name(),
+							index: index,
+							typ:   ft,
+						})
+					}
+					continue
+				}
+				if sf.PkgPath != "" || tags[0] != "" && !found {
+					// the unexported embedded struct only contributes
+					// its promoted fields
+					continue
+				}
+				if name == "" {
+					name = sf.Name
+				}
+				def, hasDef := sf.Tag.Lookup("default")
+				fields = append(fields, field{
+					name:   name,
+					tag:    tag,
+					opts:   opts,
+					tagged: tagged,
+					index:  index,
+					typ:    sf.Type,
+					def:    def,
+					hasDef: hasDef,
+					rules:  sf.Tag.Get("validate"),
+				})
+				if count[f.typ] > 1 {
+					// annihilate the field with the same name
+					// at the same depth from the duplicated embedded type
+					fields = append(fields, fields[len(fields)-1])
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return byIndex(x[i].index, x[j].index)
+	})
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+	fields = out
+	sort.Slice(fields, func(i, j int) bool {
+		return byIndex(fields[i].index, fields[j].index)
+	})
+	return fields
+}
+
+func byIndex(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// dominantField looks through the fields, all of which are known to
+// have the same name, to find the single field that dominates the
+// others. The fields are sorted by depth then by tagged-ness.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) &&
+		fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// fieldsByKey maps the typeFields by its key name.
+func fieldsByKey(t reflect.Type, tags ...string) map[string]field {
+	fields := typeFields(t, tags...)
+	result := make(map[string]field, len(fields))
+	for _, f := range fields {
+		result[f.name] = f
+	}
+	return result
+}
+
+// fieldByIndex returns the nested field of v, the returned value is
+// invalid when one of the embedded pointers along the way is nil.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldByIndexAlloc returns the nested field of v, allocating
+// the nil embedded pointers along the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
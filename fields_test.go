@@ -0,0 +1,115 @@
+package smapping
+
+import (
+	"testing"
+)
+
+type (
+	PromotedBase struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	PromotedExtra struct {
+		Name  string `json:"name"`
+		Extra string `json:"extra"`
+	}
+	promotedConflictA struct {
+		Dup string
+	}
+	promotedConflictB struct {
+		Dup string
+	}
+	PromotedNamed struct {
+		Value int `json:"value"`
+	}
+	promotedOuter struct {
+		PromotedBase
+		*PromotedExtra
+		promotedConflictA
+		promotedConflictB
+		PromotedNamed `json:"named"`
+		Name          string `json:"name"`
+	}
+	promotedPtr struct {
+		*PromotedBase
+		Own string `json:"own"`
+	}
+)
+
+func TestMapTags_embeddedPromotion(t *testing.T) {
+	obj := promotedOuter{
+		PromotedBase:  PromotedBase{ID: 1, Name: "base"},
+		PromotedExtra: &PromotedExtra{Name: "extra", Extra: "more"},
+		PromotedNamed: PromotedNamed{Value: 5},
+		Name:          "outer",
+	}
+	m := MapTags(&obj, "json")
+	if m["id"] != 1 {
+		t.Errorf("expected promoted id 1, got %#v", m["id"])
+	}
+	if m["name"] != "outer" {
+		t.Errorf("expected shallowest name 'outer', got %#v", m["name"])
+	}
+	if m["extra"] != "more" {
+		t.Errorf("expected promoted extra from pointer, got %#v", m["extra"])
+	}
+	named, ok := m["named"].(Mapped)
+	if !ok || named["value"] != 5 {
+		t.Errorf("tagged embedded struct should be nested, got %#v", m["named"])
+	}
+
+	obj.PromotedExtra = nil
+	m = MapTags(&obj, "json")
+	if _, ok := m["extra"]; ok {
+		t.Errorf("field of nil embedded pointer should be skipped")
+	}
+
+	mf := MapFields(&promotedPtr{PromotedBase: &PromotedBase{ID: 2}, Own: "own"})
+	if mf["ID"] != 2 || mf["Own"] != "own" {
+		t.Errorf("expected promoted fields by name, got %#v", mf)
+	}
+
+	mf = MapFields(&obj)
+	if _, ok := mf["Dup"]; ok {
+		t.Errorf("conflicting Dup at the same depth should be dropped")
+	}
+	if _, ok := mf["promotedConflictA"]; ok {
+		t.Errorf("embedded struct should be promoted instead of nested")
+	}
+}
+
+func TestFillStructByTags_embeddedPromotion(t *testing.T) {
+	var obj promotedOuter
+	err := FillStructByTags(&obj, Mapped{
+		"id":    3,
+		"name":  "outer",
+		"extra": "allocated",
+		"named": Mapped{"value": 7},
+	}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.ID != 3 || obj.Name != "outer" || obj.PromotedBase.Name != "" {
+		t.Errorf("wrong promoted value %#v", obj)
+	}
+	if obj.PromotedExtra == nil || obj.Extra != "allocated" {
+		t.Errorf("embedded pointer should be allocated, got %#v", obj.PromotedExtra)
+	}
+	if obj.PromotedNamed.Value != 7 {
+		t.Errorf("expected named value 7, got %d", obj.PromotedNamed.Value)
+	}
+
+	var ptr promotedPtr
+	if err := FillStruct(&ptr, Mapped{"Own": "own"}); err != nil {
+		t.Fatal(err)
+	}
+	if ptr.PromotedBase != nil {
+		t.Errorf("embedded pointer should stay nil when no promoted field is filled")
+	}
+	if err := FillStruct(&ptr, Mapped{"ID": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if ptr.PromotedBase == nil || ptr.ID != 4 {
+		t.Errorf("expected allocated embedded pointer with ID 4, got %#v", ptr.PromotedBase)
+	}
+}
@@ -0,0 +1,337 @@
+package smapping
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	s "strings"
+)
+
+/*
+MapTagsFlattenSep flattens the struct x into the keys prefixed with the
+keys of the outer fields joined by sep, e.g. "address.city" for sep "."
+or "address_city" for sep "_". The elements of slices and arrays are
+keyed by its index, e.g. "items.0.name", and the map fields by its keys.
+The empty nested values are kept as is. The sep defaults to "." when
+it's empty. Unlike MapTagsFlatten, the same tag names in different
+nested structs don't collide.
+*/
+func MapTagsFlattenSep(x interface{}, tag, sep string) Mapped {
+	return defaultMapper.mapTagsFlattenSep(x, tag, sep)
+}
+
+func (m *Mapper) mapTagsFlattenSep(x interface{}, tag, sep string) Mapped {
+	mapped := m.mapTags(x, tag)
+	if mapped == nil {
+		return nil
+	}
+	if sep == "" {
+		sep = "."
+	}
+	result := make(Mapped, len(mapped))
+	flattenValue(result, "", sep, reflect.ValueOf(mapped))
+	return result
+}
+
+// flattenValue puts the leaves of val into result with the keys
+// prefixed by prefix.
+func flattenValue(result Mapped, prefix, sep string, val reflect.Value) {
+	if val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + sep + key
+	}
+	switch {
+	case !val.IsValid():
+		result[prefix] = nil
+	case (isStringMap(val) || val.Kind() == reflect.Slice || val.Kind() == reflect.Array) &&
+		val.Len() == 0 && prefix != "":
+		result[prefix] = val.Interface()
+	case isStringMap(val):
+		iter := val.MapRange()
+		for iter.Next() {
+			flattenValue(result, join(iter.Key().String()), sep, iter.Value())
+		}
+	case (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) &&
+		val.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < val.Len(); i++ {
+			flattenValue(result, join(strconv.Itoa(i)), sep, val.Index(i))
+		}
+	default:
+		result[prefix] = val.Interface()
+	}
+}
+
+/*
+Unflatten rebuilds the nested Mapped from the flat keys joined by sep
+such as produced by MapTagsFlattenSep. The nested values keyed by all
+the indices from 0 become []interface{}, e.g. "items.0.name" and
+"items.1.name" become the slice of two Mapped. The sep defaults to "."
+when it's empty. It fails when a key is both a value and the prefix of
+another key, e.g. "address" and "address.city".
+The keys are split at every sep since there's no struct to resolve
+them against, use FillStructDeflateSep for the keys having sep such as
+the snake_case keys with sep "_".
+*/
+func Unflatten(flat Mapped, sep string) (Mapped, error) {
+	if sep == "" {
+		sep = "."
+	}
+	result := make(Mapped)
+	// nodes are the Mapped created for the prefixes
+	nodes := map[string]Mapped{"": result}
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	// sorted so the conflicts are reported consistently
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts := s.Split(key, sep)
+		node, prefix := result, ""
+		for _, part := range parts[:len(parts)-1] {
+			prefix += sep + part
+			child, ok := nodes[prefix]
+			if !ok {
+				if _, leaf := node[part]; leaf {
+					return nil, fmt.Errorf("flat key %q conflicts with the value of %q",
+						key, s.TrimPrefix(prefix, sep))
+				}
+				child = make(Mapped)
+				nodes[prefix] = child
+				node[part] = child
+			}
+			node = child
+		}
+		last := parts[len(parts)-1]
+		if _, ok := nodes[prefix+sep+last]; ok {
+			return nil, fmt.Errorf("flat key %q conflicts with the nested keys", key)
+		}
+		node[last] = flat[key]
+	}
+	return unflattenSlices(result).(Mapped), nil
+}
+
+// unflattenSlices converts the nested Mapped keyed by all the indices
+// from 0 into []interface{}.
+func unflattenSlices(node Mapped) interface{} {
+	isSlice := len(node) > 0
+	for k, v := range node {
+		if nested, ok := v.(Mapped); ok && len(nested) > 0 {
+			node[k] = unflattenSlices(nested)
+		}
+		if i, err := strconv.Atoi(k); err != nil || i < 0 || i >= len(node) ||
+			strconv.Itoa(i) != k {
+			isSlice = false
+		}
+	}
+	if !isSlice {
+		return node
+	}
+	result := make([]interface{}, len(node))
+	for k, v := range node {
+		i, _ := strconv.Atoi(k)
+		result[i] = v
+	}
+	return result
+}
+
+/*
+FillStructDeflateSep fills the nested struct obj from the flat keys
+joined by sep such as produced by MapTagsFlattenSep, e.g. "address.city"
+fills the city of the address field and "items.0.name" fills the name
+of the first element of items. The keys are resolved against the
+fields, the longest matching field key wins at each level so the keys
+having sep such as "post_code" with sep "_" are kept together. The keys
+without matching field are ignored unless Config.ErrorUnused is set
+just like FillStruct. The sep defaults to "." when it's empty.
+*/
+func FillStructDeflateSep(obj interface{}, mapped Mapped, tag, sep string) error {
+	return defaultMapper.fillStructDeflateSep(obj, mapped, tag, sep)
+}
+
+func (m *Mapper) fillStructDeflateSep(obj interface{}, mapped Mapped, tag, sep string) error {
+	if sep == "" {
+		sep = "."
+	}
+	errs := &MultiError{}
+	nested := m.nestStruct(extractValue(obj).Type(), mapped, tag, sep, "", errs)
+	if m.config.ErrorMode == ErrorIgnore || len(errs.Errors) == 0 {
+		return m.fillStruct(obj, nested, tag)
+	}
+	if m.config.ErrorMode == ErrorFailFast {
+		errs.Errors = errs.Errors[:1]
+		return errs
+	}
+	if err := m.fillStruct(obj, nested, tag); err != nil {
+		errs.add("", err)
+	}
+	return errs
+}
+
+// hasChildren reports whether the values of typ can be nested in the
+// flat keys, i.e. the structs, the slices, the arrays and the string maps.
+func hasChildren(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		return typ.Elem().Kind() != reflect.Uint8
+	case reflect.Map:
+		return typ.Key().Kind() == reflect.String
+	}
+	return nestedStruct(typ)
+}
+
+// splitFlatKey returns the field of the longest key of plan prefixing
+// the flat key together with the rest of the key after sep.
+func (m *Mapper) splitFlatKey(plan *structPlan, key, sep string) (field, string, bool) {
+	for end := len(key); end > 0; end = s.LastIndex(key[:end], sep) {
+		f, ok := m.lookupField(plan.byKey, key[:end])
+		if !ok {
+			continue
+		}
+		if end == len(key) {
+			return f, "", true
+		}
+		if hasChildren(f.typ) {
+			return f, key[end+len(sep):], true
+		}
+	}
+	return field{}, "", false
+}
+
+// MaxFlatIndex is the maximum slice index of the flat keys filled by
+// FillStructDeflateSep, the greater indices are reported as out of
+// range instead of allocating such long slices. The arrays are bounded
+// by its length instead.
+const MaxFlatIndex = 1<<16 - 1
+
+// unusedFlat reports the flat keys without matching field when the
+// Mapper reports the unused keys, the empty key is the path itself.
+func (m *Mapper) unusedFlat(errs *MultiError, path, tag string, flat Mapped) {
+	if !m.config.ErrorUnused {
+		return
+	}
+	for key, v := range flat {
+		errs.add(joinPath(path, key), &FieldError{Key: key, Tag: tag,
+			Value: v, Err: ErrUnusedKey})
+	}
+}
+
+// nestStruct rebuilds the Mapped of the struct typ from the flat keys
+// relative to it.
+func (m *Mapper) nestStruct(typ reflect.Type, flat Mapped, tag, sep, path string,
+	errs *MultiError) Mapped {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	plan := m.plan(typ, tag)
+	groups := make(map[string]Mapped)
+	fields := make(map[string]field)
+	for key, v := range flat {
+		f, rest, ok := m.splitFlatKey(plan, key, sep)
+		if !ok {
+			m.unusedFlat(errs, path, tag, Mapped{key: v})
+			continue
+		}
+		if groups[f.name] == nil {
+			groups[f.name] = make(Mapped)
+			fields[f.name] = f
+		}
+		groups[f.name][rest] = v
+	}
+	result := make(Mapped, len(groups))
+	for name, group := range groups {
+		result[name] = m.nestFlat(fields[name].typ, group, tag, sep,
+			joinPath(path, name), errs)
+	}
+	return result
+}
+
+// nestFlat rebuilds the value of typ from the flat keys relative to it,
+// the empty key is the value itself.
+func (m *Mapper) nestFlat(typ reflect.Type, flat Mapped, tag, sep, path string,
+	errs *MultiError) interface{} {
+	if v, ok := flat[""]; ok {
+		if len(flat) > 1 {
+			errs.add(path, &FieldError{Key: path, Tag: tag, Type: typ, Value: v,
+				Err: fmt.Errorf("the value conflicts with the nested keys")})
+		}
+		return v
+	}
+	elem := typ
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	switch {
+	case !hasChildren(elem):
+		m.unusedFlat(errs, path, tag, flat)
+		return nil
+	case elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array:
+		limit := MaxFlatIndex
+		if elem.Kind() == reflect.Array {
+			limit = elem.Len() - 1
+		}
+		groups := make(map[int]Mapped)
+		size := 0
+		for key, v := range flat {
+			index, rest := key, ""
+			if i := s.Index(key, sep); i >= 0 {
+				index, rest = key[:i], key[i+len(sep):]
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil || n < 0 || strconv.Itoa(n) != index {
+				m.unusedFlat(errs, path+"["+index+"]", tag, Mapped{rest: v})
+				continue
+			}
+			if n > limit {
+				errs.add(path+"["+index+"]", &FieldError{Key: index, Tag: tag,
+					Type: elem, Value: v,
+					Err: fmt.Errorf("index %d out of range, the maximum is %d", n, limit)})
+				continue
+			}
+			if groups[n] == nil {
+				groups[n] = make(Mapped)
+			}
+			groups[n][rest] = v
+			if n >= size {
+				size = n + 1
+			}
+		}
+		result := make([]interface{}, size)
+		for n, group := range groups {
+			result[n] = m.nestFlat(elem.Elem(), group, tag, sep,
+				path+"["+strconv.Itoa(n)+"]", errs)
+		}
+		return result
+	case elem.Kind() == reflect.Map:
+		if !hasChildren(elem.Elem()) {
+			return flat
+		}
+		groups := make(map[string]Mapped)
+		for key, v := range flat {
+			mapkey, rest := key, ""
+			if i := s.Index(key, sep); i >= 0 {
+				mapkey, rest = key[:i], key[i+len(sep):]
+			}
+			if groups[mapkey] == nil {
+				groups[mapkey] = make(Mapped)
+			}
+			groups[mapkey][rest] = v
+		}
+		result := make(Mapped, len(groups))
+		for mapkey, group := range groups {
+			result[mapkey] = m.nestFlat(elem.Elem(), group, tag, sep,
+				joinPath(path, mapkey), errs)
+		}
+		return result
+	}
+	return m.nestStruct(elem, flat, tag, sep, path, errs)
+}
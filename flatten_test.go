@@ -0,0 +1,177 @@
+package smapping
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type flatAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type flatItem struct {
+	Name string `json:"name"`
+	Qty  int    `json:"qty"`
+}
+
+type flatOrder struct {
+	Name     string            `json:"name"`
+	Billing  flatAddress       `json:"billing"`
+	Shipping *flatAddress      `json:"shipping"`
+	Items    []flatItem        `json:"items"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Notes    []string          `json:"notes"`
+}
+
+func TestMapTagsFlattenSep(t *testing.T) {
+	order := flatOrder{
+		Name:     "o1",
+		Billing:  flatAddress{City: "Oslo", Zip: "0150"},
+		Shipping: &flatAddress{City: "Bergen", Zip: "5003"},
+		Items:    []flatItem{{Name: "pen", Qty: 2}, {Name: "ink", Qty: 1}},
+		Tags:     []string{"gift"},
+		Labels:   map[string]string{"priority": "high"},
+		Notes:    []string{},
+	}
+	expected := Mapped{
+		"name":            "o1",
+		"billing_city":    "Oslo",
+		"billing_zip":     "0150",
+		"shipping_city":   "Bergen",
+		"shipping_zip":    "5003",
+		"items_0_name":    "pen",
+		"items_0_qty":     2,
+		"items_1_name":    "ink",
+		"items_1_qty":     1,
+		"tags_0":          "gift",
+		"labels_priority": "high",
+		"notes":           []interface{}{},
+	}
+	flat := MapTagsFlattenSep(order, "json", "_")
+	if !reflect.DeepEqual(flat, expected) {
+		t.Errorf("expected %#v, got %#v", expected, flat)
+	}
+
+	var got flatOrder
+	if err := FillStructDeflateSep(&got, flat, "json", "_"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, order) {
+		t.Errorf("expected %#v, got %#v", order, got)
+	}
+
+	mapper := NewMapper(Config{TagName: "json", FlattenSeparator: "."})
+	flat = mapper.MapTagsFlatten(order)
+	if flat["billing.city"] != "Oslo" || flat["items.1.name"] != "ink" {
+		t.Errorf("wrong flattened keys %v", flat)
+	}
+	got = flatOrder{}
+	if err := mapper.FillStructDeflate(&got, flat); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, order) {
+		t.Errorf("expected %#v, got %#v", order, got)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	nested, err := Unflatten(Mapped{
+		"a.b":   1,
+		"a.c.0": "x",
+		"a.c.1": "y",
+		"d.1":   true,
+		"e":     nil,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Mapped{
+		"a": Mapped{"b": 1, "c": []interface{}{"x", "y"}},
+		"d": Mapped{"1": true},
+		"e": nil,
+	}
+	if !reflect.DeepEqual(nested, expected) {
+		t.Errorf("expected %#v, got %#v", expected, nested)
+	}
+
+	for _, flat := range []Mapped{
+		{"a": 1, "a.b": 2},
+		{"a.b": 2, "a.b.c": 3},
+	} {
+		if _, err := Unflatten(flat, "."); err == nil ||
+			!strings.Contains(err.Error(), "conflicts") {
+			t.Errorf("%v: expected conflict error, got %v", flat, err)
+		}
+	}
+}
+
+func TestFillStructDeflateSep_keysWithSep(t *testing.T) {
+	type postAddr struct {
+		PostCode string `json:"post_code"`
+		Street   string `json:"street"`
+	}
+	type user struct {
+		Name     string     `json:"name"`
+		HomeAddr postAddr   `json:"home_addr"`
+		Home     string     `json:"home"`
+		PastAddr []postAddr `json:"past_addr"`
+	}
+	obj := user{
+		Name:     "Ann",
+		HomeAddr: postAddr{PostCode: "0150", Street: "Main"},
+		Home:     "flat",
+		PastAddr: []postAddr{{PostCode: "5003"}},
+	}
+	flat := MapTagsFlattenSep(obj, "json", "_")
+	if flat["home_addr_post_code"] != "0150" || flat["past_addr_0_post_code"] != "5003" {
+		t.Errorf("wrong flattened keys %v", flat)
+	}
+	var got user
+	if err := FillStructDeflateSep(&got, flat, "json", "_"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, obj) {
+		t.Errorf("expected %#v, got %#v", obj, got)
+	}
+
+	flat["home_addr_zip"] = "0151"
+	flat["past_addr_x_street"] = "Old"
+	if err := FillStructDeflateSep(&got, flat, "json", "_"); err != nil {
+		t.Errorf("expected the unused keys ignored, got %v", err)
+	}
+	strict := NewMapper(Config{TagName: "json", FlattenSeparator: "_", ErrorUnused: true})
+	err := strict.FillStructDeflate(&got, flat)
+	var merr *MultiError
+	if !errors.As(err, &merr) || len(merr.Errors) != 2 || !errors.Is(err, ErrUnusedKey) {
+		t.Fatalf("expected two unused keys, got %v", err)
+	}
+	paths := []string{merr.Errors[0].Path, merr.Errors[1].Path}
+	sort.Strings(paths)
+	if paths[0] != "home_addr.zip" || paths[1] != "past_addr[x].street" {
+		t.Errorf("wrong unused paths %v", paths)
+	}
+}
+
+func TestFillStructDeflateSep_sparseIndices(t *testing.T) {
+	var got flatOrder
+	err := FillStructDeflateSep(&got, Mapped{"items.1.name": "only", "tags.2": "c"}, "json", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := flatOrder{Items: []flatItem{{}, {Name: "only"}}, Tags: []string{"", "", "c"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, got %#v", expected, got)
+	}
+
+	err = FillStructDeflateSep(&got, Mapped{"items.70000.name": "far"}, "json", ".")
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "items[70000]" ||
+		!strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected index out of range at items[70000], got %v", err)
+	}
+}
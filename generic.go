@@ -0,0 +1,71 @@
+package smapping
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// newValue returns the zero T with the pointer allocated when T is
+// a pointer so it can be filled.
+func newValue[T any]() T {
+	var t T
+	if v := reflect.ValueOf(&t).Elem(); v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return t
+}
+
+// fillTarget returns the fillable pointer of t.
+func fillTarget[T any](t *T) interface{} {
+	if v := reflect.ValueOf(t).Elem(); v.Kind() == reflect.Ptr {
+		return v.Interface()
+	}
+	return t
+}
+
+/*
+FillNew returns the new T filled from mapped with the tag just like
+FillStructByTags, the empty tag matches the keys with the field names.
+T is either a struct or a pointer to struct.
+*/
+func FillNew[T any](mapped Mapped, tag string) (T, error) {
+	t := newValue[T]()
+	err := defaultMapper.fillStruct(fillTarget(&t), mapped, tag)
+	return t, err
+}
+
+/*
+MapTo converts the struct x to T through the Mapped of the tag, the fields
+of both structs are matched by its tag value. It's the one-liner of
+MapTags followed by FillNew.
+*/
+func MapTo[T any](x interface{}, tag string) (T, error) {
+	return FillNew[T](defaultMapper.mapTags(x, tag), tag)
+}
+
+// MapSlice maps each struct of xs with MapTags.
+func MapSlice[T any](xs []T, tag string) []Mapped {
+	result := make([]Mapped, len(xs))
+	for i, x := range xs {
+		result[i] = defaultMapper.mapTags(x, tag)
+	}
+	return result
+}
+
+/*
+FillSlice returns the new slice of T filled from each of mapped with
+FillNew. The failing fields are reported as *MultiError with the index
+of the element as the path prefix, e.g. "[2].fieldInt".
+*/
+func FillSlice[T any](mapped []Mapped, tag string) ([]T, error) {
+	errs := &MultiError{}
+	result := make([]T, len(mapped))
+	for i, m := range mapped {
+		t, err := FillNew[T](m, tag)
+		if err != nil {
+			errs.add("["+strconv.Itoa(i)+"]", err)
+		}
+		result[i] = t
+	}
+	return result, errs.errorOrNil()
+}
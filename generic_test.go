@@ -0,0 +1,66 @@
+package smapping
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func ExampleMapTo() {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Pass string `json:"-"`
+	}
+	type userView struct {
+		Name string `json:"name"`
+		ID   int64  `json:"id"`
+	}
+	view, err := MapTo[userView](user{ID: 1, Name: "smapping", Pass: "secret"}, "json")
+	fmt.Println(err)
+	fmt.Printf("%+v\n", view)
+
+	// Output:
+	// <nil>
+	// {Name:smapping ID:1}
+}
+
+func TestFillNew(t *testing.T) {
+	obj, err := FillNew[embedObj](Mapped{"fieldInt": 1, "fieldStr": "one"}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.FieldInt != 1 || obj.FieldStr != "one" {
+		t.Errorf("wrong filled object %#v", obj)
+	}
+
+	ptr, err := FillNew[*embedObj](Mapped{"FieldFloat": 1.5}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ptr == nil || ptr.FieldFloat != 1.5 {
+		t.Errorf("wrong filled pointer %#v", ptr)
+	}
+}
+
+func TestFillSlice(t *testing.T) {
+	objs := []embedObj{{1, "one", 1.1}, {2, "two", 2.2}}
+	mapped := MapSlice(objs, "json")
+	if len(mapped) != 2 || mapped[1]["fieldStr"] != "two" {
+		t.Errorf("wrong mapped slice %#v", mapped)
+	}
+	filled, err := FillSlice[embedObj](mapped, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filled) != 2 || filled[0] != objs[0] || filled[1] != objs[1] {
+		t.Errorf("wrong filled slice %#v", filled)
+	}
+
+	mapped[1]["fieldInt"] = "two"
+	_, err = FillSlice[embedObj](mapped, "json")
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "[1].fieldInt" {
+		t.Errorf("expected error at [1].fieldInt, got %v", err)
+	}
+}
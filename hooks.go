@@ -0,0 +1,88 @@
+package smapping
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	s "strings"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	ipType   = reflect.TypeOf(net.IP{})
+)
+
+/*
+ComposeDecodeHookFunc chains the hooks into a single DecodeHookFunc.
+The hooks are called in order with the data returned by the previous
+hook and its type as from. The chain stops at the first error or when
+a hook returns nil, leaving the field untouched.
+*/
+func ComposeDecodeHookFunc(hooks ...DecodeHookFunc) DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		var err error
+		for _, hook := range hooks {
+			if data, err = hook(from, to, data); err != nil || data == nil {
+				return data, err
+			}
+			from = reflect.TypeOf(data)
+		}
+		return data, nil
+	}
+}
+
+// StringToSliceHookFunc splits the string by sep for the slice fields
+// except the byte slices such as net.IP, the empty string becomes the
+// empty slice.
+func StringToSliceHookFunc(sep string) DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to.Kind() != reflect.Slice ||
+			to.Elem().Kind() == reflect.Uint8 {
+			return data, nil
+		}
+		str := reflect.ValueOf(data).String()
+		if str == "" {
+			return []string{}, nil
+		}
+		return s.Split(str, sep), nil
+	}
+}
+
+// StringToTimeHookFunc parses the string with the layout for the time.Time
+// and *time.Time fields.
+func StringToTimeHookFunc(layout string) DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String ||
+			(to != timeType && to != reflect.PtrTo(timeType)) {
+			return data, nil
+		}
+		return time.Parse(layout, reflect.ValueOf(data).String())
+	}
+}
+
+// StringToTimeDurationHookFunc parses the string such as "1h30m" for
+// the time.Duration fields.
+func StringToTimeDurationHookFunc() DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != durationType {
+			return data, nil
+		}
+		return time.ParseDuration(reflect.ValueOf(data).String())
+	}
+}
+
+// StringToIPHookFunc parses the string for the net.IP fields.
+func StringToIPHookFunc() DecodeHookFunc {
+	return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to != ipType {
+			return data, nil
+		}
+		str := reflect.ValueOf(data).String()
+		ip := net.ParseIP(str)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", str)
+		}
+		return ip, nil
+	}
+}
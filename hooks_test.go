@@ -0,0 +1,100 @@
+package smapping
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleComposeDecodeHookFunc() {
+	type server struct {
+		Hosts   []string      `json:"hosts"`
+		Addr    net.IP        `json:"addr"`
+		Started time.Time     `json:"started"`
+		Timeout time.Duration `json:"timeout"`
+	}
+	mapper := NewMapper(Config{
+		TagName: "json",
+		DecodeHook: ComposeDecodeHookFunc(
+			StringToSliceHookFunc(","),
+			StringToTimeHookFunc("2006-01-02"),
+			StringToIPHookFunc(),
+			StringToTimeDurationHookFunc(),
+		),
+	})
+	var srv server
+	err := mapper.FillStruct(&srv, Mapped{
+		"hosts":   "a.example.com,b.example.com",
+		"addr":    "10.0.0.1",
+		"started": "2021-03-04",
+		"timeout": "1m30s",
+	})
+	fmt.Println(err)
+	fmt.Println(srv.Hosts, srv.Addr, srv.Started.Format(time.RFC3339), srv.Timeout)
+
+	// Output:
+	// <nil>
+	// [a.example.com b.example.com] 10.0.0.1 2021-03-04T00:00:00Z 1m30s
+}
+
+func TestComposeDecodeHookFunc(t *testing.T) {
+	var calls []string
+	record := func(name string, result interface{}) DecodeHookFunc {
+		return func(from, to reflect.Type, data interface{}) (interface{}, error) {
+			calls = append(calls, fmt.Sprintf("%s:%v", name, from))
+			if result != nil {
+				return result, nil
+			}
+			return nil, nil
+		}
+	}
+	hook := ComposeDecodeHookFunc(record("first", 1), record("second", nil), record("third", 3))
+	data, err := hook(reflect.TypeOf(""), reflect.TypeOf(0), "zero")
+	if err != nil || data != nil {
+		t.Errorf("expected the chain stopped with nil, got %v %v", data, err)
+	}
+	if strings.Join(calls, " ") != "first:string second:int" {
+		t.Errorf("wrong calls %v", calls)
+	}
+
+	mapper := NewMapper(Config{DecodeHook: ComposeDecodeHookFunc(StringToIPHookFunc())})
+	var obj struct{ Addr net.IP }
+	err = mapper.FillStruct(&obj, Mapped{"Addr": "localhost"})
+	if err == nil || !strings.Contains(err.Error(), "invalid IP address") {
+		t.Errorf("expected invalid IP error, got %v", err)
+	}
+}
+
+func TestDecodeHook_elements(t *testing.T) {
+	yes := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if s, ok := data.(string); ok && to.Kind() == reflect.Bool {
+			return s == "yes", nil
+		}
+		return data, nil
+	}
+	mapper := NewMapper(Config{TagName: "json", DecodeHook: yes})
+	var obj struct {
+		Flags    []bool          `json:"flags"`
+		Fixed    [2]bool         `json:"fixed"`
+		Features map[string]bool `json:"features"`
+		Counts   []int           `json:"counts"`
+	}
+	err := mapper.FillStruct(&obj, Mapped{
+		"flags":    []interface{}{"yes", "no", true},
+		"fixed":    []string{"no", "yes"},
+		"features": Mapped{"a": "yes", "b": "no"},
+		"counts":   []interface{}{1, 2.0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(obj.Flags, []bool{true, false, true}) ||
+		obj.Fixed != [2]bool{false, true} ||
+		!reflect.DeepEqual(obj.Features, map[string]bool{"a": true, "b": false}) ||
+		!reflect.DeepEqual(obj.Counts, []int{1, 2}) {
+		t.Errorf("wrong hooked elements %#v", obj)
+	}
+}
@@ -0,0 +1,193 @@
+package smapping
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrorMode decides how the Mapper handles the failing fields
+// when filling the struct.
+type ErrorMode int
+
+const (
+	// ErrorCollect keeps filling the rest of fields and reports
+	// all of the failing fields. This is the default mode.
+	ErrorCollect ErrorMode = iota
+	// ErrorFailFast stops filling at the first failing field.
+	ErrorFailFast
+	// ErrorIgnore skips the failing fields without reporting them.
+	ErrorIgnore
+)
+
+/*
+DecodeHookFunc is called with the type of the value, the type of the
+field and the value itself before the value is assigned to the field.
+The returned value is assigned instead and the returned error is reported
+as the field error. Returning nil value leaves the field untouched.
+*/
+type DecodeHookFunc func(from, to reflect.Type, data interface{}) (interface{}, error)
+
+// Config is the configuration of Mapper. The zero value is the
+// behavior of the package level functions.
+type Config struct {
+	// TagName is the struct tag used for the keys. The empty TagName
+	// means the field names are used as keys.
+	TagName string
+
+	// FallbackTags are looked up in order when the field doesn't
+	// have the TagName tag.
+	FallbackTags []string
+
+	// TimeLayouts are tried in order when parsing a string into
+	// time.Time. Defaults to time.RFC3339. The layouts can be the names
+	// of time package layouts such as "RFC1123" and the pseudo layouts
+	// "unix", "unixmilli", "unixmicro" and "unixnano" of the epoch.
+	// The numbers are filled as the epoch in the unit of the first
+	// pseudo layout, or in seconds when there's none.
+	// The field can have its own layout with the tag option such as
+	// `json:"born,layout=2006-01-02"` which is used for MapTags too.
+	// The time.Duration field with `json:"timeout,duration=string"` is
+	// mapped as the string such as "1h30m0s".
+	TimeLayouts []string
+
+	// CaseInsensitive matches the keys with the fields regardless of
+	// its case when there's no exact match.
+	CaseInsensitive bool
+	// KeyNaming converts the field names into the keys of the fields
+	// without tag name, e.g. SnakeCase maps UserID to "user_id". When
+	// filling the struct, the keys without exact match are converted
+	// too so "userId" and "UserID" match the field of "user_id".
+	KeyNaming NamingStrategy
+	// MapKeyNaming converts every key of Mapped produced by MapTags and
+	// MapTagsFlatten, including the tag values, e.g. CamelCase emits the
+	// field tagged "user_id" as "userId". The nested structs are
+	// converted too while the keys of the map fields are kept.
+	MapKeyNaming NamingStrategy
+	// MapKeyFunc returns the key of Mapped for the struct field, taking
+	// precedence over MapKeyNaming. Returning the empty string keeps
+	// the key of the tag.
+	MapKeyFunc func(field reflect.StructField) string
+	// FlattenSeparator makes MapTagsFlatten and FillStructDeflate use
+	// the keys prefixed with the outer keys joined by the separator,
+	// see MapTagsFlattenSep and FillStructDeflateSep.
+	FlattenSeparator string
+
+	// WeaklyTyped enables the conversion between strings and scalar
+	// values, see FillStructWeak.
+	WeaklyTyped bool
+
+	// JSONCodec uses json.Marshaler and json.Unmarshaler of the field
+	// types when they have neither MapEncoder/MapDecoder nor
+	// encoding.TextMarshaler/TextUnmarshaler.
+	JSONCodec bool
+
+	// ErrorUnused reports the keys without matching field as the field
+	// error of ErrUnusedKey, except for FillStructDeflate whose flat keys
+	// belong to the nested structs.
+	ErrorUnused bool
+
+	// ErrorMode decides how the failing fields are reported.
+	ErrorMode ErrorMode
+
+	// DecodeHook is called before each field assignment when filling
+	// the struct. Several hooks can be chained with ComposeDecodeHookFunc.
+	DecodeHook DecodeHookFunc
+	// Validate checks the rules of the validate tag after FillStruct and
+	// FillStructDeflate, the failing rules are reported together with the
+	// failing fields. See Mapper.Validate.
+	Validate bool
+}
+
+/*
+Mapper maps the struct to Mapped and fills the struct from Mapped
+according to its Config. The package level functions are the thin
+wrappers of the Mapper with zero Config.
+Mapper is safe for concurrent use.
+*/
+type Mapper struct {
+	config Config
+
+	// plans caches the *structPlan by planKey.
+	plans sync.Map
+	// converters caches the *Converter by convKey.
+	converters sync.Map
+
+	registry registry
+}
+
+var (
+	defaultMapper = &Mapper{}
+	weakMapper    = &Mapper{config: Config{WeaklyTyped: true}}
+)
+
+// NewMapper returns the Mapper with the config.
+func NewMapper(config Config) *Mapper {
+	return &Mapper{config: config}
+}
+
+// Config returns the configuration of the Mapper.
+func (m *Mapper) Config() Config {
+	return m.config
+}
+
+// tags returns the tag with the fallback tags to look up.
+func (m *Mapper) tags(tag string) []string {
+	if tag == "" || len(m.config.FallbackTags) == 0 {
+		return []string{tag}
+	}
+	return append([]string{tag}, m.config.FallbackTags...)
+}
+
+func (m *Mapper) timeLayouts() []string {
+	if len(m.config.TimeLayouts) == 0 {
+		return []string{time.RFC3339}
+	}
+	return m.config.TimeLayouts
+}
+
+// MapTags maps the struct x to Mapped, just like the package level MapTags
+// with the configured TagName and FallbackTags.
+func (m *Mapper) MapTags(x interface{}) Mapped {
+	return m.mapTags(x, m.config.TagName)
+}
+
+// MapTagsE maps the struct x to Mapped and reports the errors just like
+// the package level MapTagsE with the configured tags.
+func (m *Mapper) MapTagsE(x interface{}) (Mapped, error) {
+	return m.mapTagsE(x, m.config.TagName)
+}
+
+// MapTagsFlatten flattens the struct x just like the package level
+// MapTagsFlatten with the configured tags, or MapTagsFlattenSep when
+// FlattenSeparator is set.
+func (m *Mapper) MapTagsFlatten(x interface{}) Mapped {
+	if m.config.FlattenSeparator != "" {
+		return m.mapTagsFlattenSep(x, m.config.TagName, m.config.FlattenSeparator)
+	}
+	return m.mapTagsFlatten(x, m.config.TagName)
+}
+
+// FillStruct fills the struct obj from mapped, matching the keys with
+// the configured tags or the field names when TagName is empty.
+func (m *Mapper) FillStruct(obj interface{}, mapped Mapped) error {
+	return m.validated(obj, m.config.TagName, m.fillStruct(obj, mapped, m.config.TagName))
+}
+
+// FillStructDeflate fills the nested struct obj from flat mapped just like
+// the package level FillStructDeflate with the configured tags, or
+// FillStructDeflateSep when FlattenSeparator is set.
+func (m *Mapper) FillStructDeflate(obj interface{}, mapped Mapped) error {
+	if sep := m.config.FlattenSeparator; sep != "" {
+		return m.validated(obj, m.config.TagName,
+			m.fillStructDeflateSep(obj, mapped, m.config.TagName, sep))
+	}
+	return m.validated(obj, m.config.TagName,
+		m.fillStructDeflate(obj, mapped, m.config.TagName))
+}
+
+// SQLScan scans the row into obj just like the package level SQLScan
+// with the configured tags.
+func (m *Mapper) SQLScan(row SQLScanner, obj interface{}, fields ...string) error {
+	return m.sqlScan(row, obj, m.config.TagName, fields...)
+}
@@ -0,0 +1,111 @@
+package smapping
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleNewMapper() {
+	type user struct {
+		Name  string    `json:"name"`
+		Email string    `json:"email" api:"contact"`
+		Born  time.Time `json:"born"`
+	}
+	mapper := NewMapper(Config{
+		TagName:         "api",
+		FallbackTags:    []string{"json"},
+		TimeLayouts:     []string{"2006-01-02", time.RFC3339},
+		CaseInsensitive: true,
+	})
+	var u user
+	err := mapper.FillStruct(&u, Mapped{
+		"NAME":    "smapping",
+		"contact": "smapping@example.com",
+		"born":    "2018-01-02",
+	})
+	fmt.Println(err)
+	fmt.Println(u.Name, u.Email, u.Born.Format(time.RFC3339))
+	m := mapper.MapTags(&u)
+	fmt.Println(m["name"], m["contact"])
+
+	// Output:
+	// <nil>
+	// smapping smapping@example.com 2018-01-02T00:00:00Z
+	// smapping smapping@example.com
+}
+
+func TestMapper_errorMode(t *testing.T) {
+	type target struct {
+		Int   int    `json:"int"`
+		Bool  bool   `json:"bool"`
+		Label string `json:"label"`
+	}
+	mapped := Mapped{"int": "one", "bool": "true", "label": "label"}
+
+	var obj target
+	err := NewMapper(Config{TagName: "json"}).FillStruct(&obj, mapped)
+	if err == nil || !strings.Contains(err.Error(), "int") ||
+		!strings.Contains(err.Error(), "bool") {
+		t.Errorf("expected both fields reported, got %v", err)
+	}
+
+	obj = target{}
+	err = NewMapper(Config{TagName: "json", ErrorMode: ErrorFailFast}).
+		FillStruct(&obj, mapped)
+	if err == nil || strings.Contains(err.Error(), ",") {
+		t.Errorf("expected single error, got %v", err)
+	}
+
+	obj = target{}
+	err = NewMapper(Config{TagName: "json", ErrorMode: ErrorIgnore}).
+		FillStruct(&obj, mapped)
+	if err != nil {
+		t.Errorf("expected ignored error, got %v", err)
+	}
+	if obj.Label != "label" {
+		t.Errorf("expected label filled, got %#v", obj)
+	}
+}
+
+func TestMapper_decodeHook(t *testing.T) {
+	type target struct {
+		Names []string
+		Count int
+	}
+	mapper := NewMapper(Config{
+		DecodeHook: func(from, to reflect.Type, data interface{}) (interface{}, error) {
+			if from.Kind() == reflect.String && to.Kind() == reflect.Slice {
+				return strings.Split(data.(string), ","), nil
+			}
+			if from.Kind() == reflect.Bool {
+				return nil, fmt.Errorf("bool is not accepted")
+			}
+			return data, nil
+		},
+	})
+	var obj target
+	err := mapper.FillStruct(&obj, Mapped{"Names": "a,b", "Count": true})
+	if err == nil || !strings.Contains(err.Error(), "bool is not accepted") {
+		t.Errorf("expected hook error, got %v", err)
+	}
+	if len(obj.Names) != 2 || obj.Names[1] != "b" {
+		t.Errorf("expected names split by hook, got %#v", obj.Names)
+	}
+}
+
+func TestMapper_sqlScan(t *testing.T) {
+	currtime := time.Now()
+	dr := createDummyRow(currtime)
+	result := dummyValues{}
+	err := NewMapper(Config{}).SQLScan(dr, &result, "Int16", "String", "NullString")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Int16 != -3 || result.String != "hello 異世界" ||
+		!result.NullString.Valid {
+		t.Errorf("wrong scanned values %#v", result)
+	}
+}
@@ -0,0 +1,140 @@
+package smapping
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	textMarshalerI   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerI = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// typeField returns the field of typ without name, it's used for
+// converting the elements of maps and slices like the struct fields.
+func typeField(typ reflect.Type) field {
+	return field{typ: typ, caps: capsOf(typ)}
+}
+
+// mapKeyString returns the key of Mapped for the map key k just like
+// encoding/json does, the other kinds are formatted with fmt.
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if marshaler, ok := asInterface(k, textMarshalerI); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return ""
+		}
+		if text, err := marshaler.(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// mapValue maps the map fieldval to Mapped with the keys formatted by
+// mapKeyString and the values mapped like the struct fields.
+func (m *Mapper) mapValue(fieldval reflect.Value, tag string) (Mapped, error) {
+	errs := &MultiError{}
+	result := make(Mapped, fieldval.Len())
+	iter := fieldval.MapRange()
+	for iter.Next() {
+		key := mapKeyString(iter.Key())
+		val, err := m.getValTagE(iter.Value(), tag)
+		if err != nil {
+			errs.add(key, encodeError(iter.Value(), tag, key, err))
+		}
+		result[key] = val
+	}
+	return result, errs.errorOrNil()
+}
+
+// mapKey converts the key k of the provided map to the key type typ.
+// The string keys are parsed when typ is an integer or implements
+// encoding.TextUnmarshaler.
+func (m *Mapper) mapKey(typ reflect.Type, k reflect.Value) (reflect.Value, error) {
+	if k.Kind() == reflect.Interface {
+		k = k.Elem()
+	}
+	if k.Kind() != reflect.String {
+		return scalarValue(k, typ, m.config.WeaklyTyped)
+	}
+	key := k.String()
+	if reflect.PtrTo(typ).Implements(textUnmarshalerI) {
+		res := reflect.New(typ)
+		err := res.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key))
+		return res.Elem(), err
+	}
+	res := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		res.SetString(key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, 64)
+		if err != nil || res.OverflowInt(n) {
+			return res, fmt.Errorf("invalid map key %q for %v", key, typ)
+		}
+		res.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, 64)
+		if err != nil || res.OverflowUint(n) {
+			return res, fmt.Errorf("invalid map key %q for %v", key, typ)
+		}
+		res.SetUint(n)
+	default:
+		return res, ErrTypeNotMatch
+	}
+	return res, nil
+}
+
+// fillMap fills the new map of typ with the entries of the map val and
+// reports the failing entries as *MultiError with the key as the path.
+func (m *Mapper) fillMap(typ reflect.Type, val *reflect.Value, tagname string) error {
+	if val.IsNil() {
+		*val = reflect.Zero(typ)
+		return nil
+	}
+	errs := &MultiError{}
+	res := reflect.MakeMapWithSize(typ, val.Len())
+	elem := typeField(typ.Elem())
+	iter := val.MapRange()
+	for iter.Next() {
+		keystr := mapKeyString(iter.Key())
+		key, err := m.mapKey(typ.Key(), iter.Key())
+		if err != nil {
+			errs.add(keystr, &FieldError{Key: keystr, Tag: tagname,
+				Type: typ.Key(), Value: iter.Key().Interface(), Err: err})
+			if m.config.ErrorMode == ErrorFailFast {
+				return errs
+			}
+			continue
+		}
+		value := iter.Value().Interface()
+		if value == nil {
+			res.SetMapIndex(key, reflect.Zero(elem.typ))
+			continue
+		}
+		v, ok, err := m.fieldValue(elem, tagname, keystr, value)
+		if err != nil {
+			errs.add(keystr, err)
+			if m.config.ErrorMode == ErrorFailFast {
+				return errs
+			}
+			continue
+		}
+		if ok {
+			res.SetMapIndex(key, v)
+		}
+	}
+	*val = res
+	return errs.errorOrNil()
+}
@@ -0,0 +1,134 @@
+package smapping
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+type mapFields struct {
+	Items   map[string]embedObj    `json:"items"`
+	ByID    map[int]*embedObj      `json:"by_id"`
+	Hosts   map[netip.Addr]string  `json:"hosts"`
+	Lists   map[uint8][]string     `json:"lists"`
+	Counts  map[string]int         `json:"counts"`
+	Nested  []map[string]embedObj  `json:"nested"`
+	Generic map[string]interface{} `json:"generic"`
+}
+
+func TestMapTags_mapFields(t *testing.T) {
+	obj := mapFields{
+		Items:  map[string]embedObj{"one": {1, "one", 1.1}},
+		ByID:   map[int]*embedObj{2: {FieldInt: 2}, 3: nil},
+		Hosts:  map[netip.Addr]string{netip.MustParseAddr("127.0.0.1"): "localhost"},
+		Lists:  map[uint8][]string{1: {"a"}},
+		Counts: map[string]int{"a": 1},
+	}
+	mapped := MapTags(&obj, "json")
+	items, ok := mapped["items"].(Mapped)
+	if !ok {
+		t.Fatalf("expected items Mapped, got %#v", mapped["items"])
+	}
+	if item, ok := items["one"].(Mapped); !ok || item["fieldStr"] != "one" {
+		t.Errorf("expected the struct value mapped, got %#v", items["one"])
+	}
+	byID := mapped["by_id"].(Mapped)
+	if byID["2"].(Mapped)["fieldInt"] != 2 || byID["3"] != nil {
+		t.Errorf("wrong mapped int keys %#v", byID)
+	}
+	if hosts := mapped["hosts"].(Mapped); hosts["127.0.0.1"] != "localhost" {
+		t.Errorf("wrong mapped text keys %#v", hosts)
+	}
+
+	var filled mapFields
+	if err := FillStructByTags(&filled, mapped, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(obj, filled) {
+		t.Errorf("expected %#v, got %#v", obj, filled)
+	}
+}
+
+func TestFillStructByTags_mapFields(t *testing.T) {
+	var obj mapFields
+	err := FillStructByTags(&obj, Mapped{
+		"items":   map[string]interface{}{"one": Mapped{"fieldInt": 1}},
+		"by_id":   Mapped{"7": Mapped{"fieldStr": "seven"}},
+		"lists":   map[string]interface{}{"1": []interface{}{"a", "b"}},
+		"nested":  []interface{}{Mapped{"x": Mapped{"fieldInt": 3}}},
+		"generic": Mapped{"any": 1.5},
+	}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Items["one"].FieldInt != 1 || obj.ByID[7].FieldStr != "seven" ||
+		len(obj.Lists[1]) != 2 || obj.Nested[0]["x"].FieldInt != 3 ||
+		obj.Generic["any"] != 1.5 {
+		t.Errorf("wrong filled maps %#v", obj)
+	}
+
+	err = FillStructByTags(&obj, Mapped{
+		"by_id":  Mapped{"x": Mapped{}},
+		"items":  Mapped{"one": Mapped{"fieldInt": "one"}},
+		"counts": Mapped{"a": "b"},
+	}, "json")
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("expected *MultiError, got %v", err)
+	}
+	paths := map[string]bool{}
+	for _, fe := range merr.Errors {
+		paths[fe.Path] = true
+	}
+	for _, path := range []string{"by_id.x", "items.one.fieldInt", "counts.a"} {
+		if !paths[path] {
+			t.Errorf("expected error at %s, got %v", path, err)
+		}
+	}
+}
+
+func TestFillStructByTags_plainMaps(t *testing.T) {
+	type labels map[string]string
+	type target struct {
+		Embed   embedEmbed     `json:"embed"`
+		Objs    []*embedObj    `json:"objs"`
+		Labeled *differentSink `json:"labeled"`
+		Named   embedObj       `json:"named"`
+		Items   []embedObjs    `json:"items"`
+		Values  []interface{}  `json:"values"`
+	}
+	var mapped Mapped
+	err := json.Unmarshal([]byte(`{
+		"embed": {"embed1": {"fieldInt": 1}, "embed2": {"fieldStr": "two"}},
+		"objs": [{"fieldInt": 1}, null, {"fieldFloat": 1.5}],
+		"items": [{"embeds": [{"fieldStr": "nested"}]}],
+		"values": [{"any": "thing"}]
+	}`), &mapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped["labeled"] = map[string]string{"label": "label"}
+	mapped["named"] = labels{"fieldStr": "named"}
+
+	var obj target
+	if err := FillStructByTags(&obj, mapped, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if obj.Embed.Embed1.FieldInt != 1 || obj.Embed.Embed2.FieldStr != "two" {
+		t.Errorf("wrong nested struct %#v", obj.Embed)
+	}
+	if len(obj.Objs) != 3 || obj.Objs[1] != nil || obj.Objs[2].FieldFloat != 1.5 {
+		t.Errorf("wrong nested slice %#v", obj.Objs)
+	}
+	if obj.Labeled == nil || obj.Labeled.DiffLabel != "label" || obj.Named.FieldStr != "named" {
+		t.Errorf("wrong filled string maps %#v %#v", obj.Labeled, obj.Named)
+	}
+	if len(obj.Items) != 1 || obj.Items[0].Objs[0].FieldStr != "nested" {
+		t.Errorf("wrong deeply nested slice %#v", obj.Items)
+	}
+	if v, ok := obj.Values[0].(map[string]interface{}); !ok || v["any"] != "thing" {
+		t.Errorf("expected the map kept as is, got %#v", obj.Values)
+	}
+}
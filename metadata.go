@@ -0,0 +1,114 @@
+package smapping
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+/*
+Metadata reports how the keys of Mapped are used by FillStruct. The keys
+and fields are the full paths just like FieldError.Path, e.g.
+"server.port" or "servers[1].port".
+*/
+type Metadata struct {
+	// Keys are the keys matching a field.
+	Keys []string
+	// Unused are the keys without matching field.
+	Unused []string
+	// Unset are the fields without value in Mapped. The fields of
+	// nested struct without value are not listed one by one.
+	Unset []string
+	// Defaulted are the fields without value in Mapped that have the
+	// default tag, they're filled with the default unless already set.
+	Defaulted []string
+}
+
+/*
+FillStructMetadata is FillStructByTags that also returns the Metadata of
+the keys used, the keys ignored, the fields left untouched and the
+fields filled with their default tag. The
+keys failing the conversion are listed in Keys and reported in the error.
+*/
+func FillStructMetadata(obj interface{}, mapped Mapped, tagname string) (Metadata, error) {
+	return defaultMapper.fillStructMetadata(obj, mapped, tagname)
+}
+
+// FillStructMetadata fills obj from mapped and returns the Metadata just
+// like the package level FillStructMetadata with the configured tags.
+func (m *Mapper) FillStructMetadata(obj interface{}, mapped Mapped) (Metadata, error) {
+	md, err := m.fillStructMetadata(obj, mapped, m.config.TagName)
+	return md, m.validated(obj, m.config.TagName, err)
+}
+
+func (m *Mapper) fillStructMetadata(obj interface{}, mapped Mapped, tagname string) (Metadata, error) {
+	err := m.fillStruct(obj, mapped, tagname)
+	md := Metadata{Keys: []string{}, Unused: []string{}, Unset: []string{},
+		Defaulted: []string{}}
+	m.collectMetadata(&md, extractValue(obj).Type(), reflect.ValueOf(mapped), tagname, "")
+	sort.Strings(md.Keys)
+	sort.Strings(md.Unused)
+	sort.Strings(md.Unset)
+	sort.Strings(md.Defaulted)
+	return md, err
+}
+
+// collectMetadata walks the string map val along the struct type typ.
+func (m *Mapper) collectMetadata(md *Metadata, typ reflect.Type, val reflect.Value,
+	tagname, prefix string) {
+	plan := m.plan(typ, tagname)
+	present := make(map[string]bool, val.Len())
+	iter := val.MapRange()
+	for iter.Next() {
+		key := iter.Key().String()
+		path := joinPath(prefix, key)
+		field, ok := m.lookupField(plan.byKey, key)
+		if !ok {
+			md.Unused = append(md.Unused, path)
+			continue
+		}
+		md.Keys = append(md.Keys, path)
+		value := iter.Value()
+		if value.Kind() == reflect.Interface {
+			value = value.Elem()
+		}
+		if !value.IsValid() || isValueNil(value) {
+			continue
+		}
+		present[field.name] = true
+		m.collectNested(md, field.typ, value, tagname, path)
+	}
+	for _, f := range plan.fields {
+		switch {
+		case present[f.name]:
+		case f.hasDef:
+			md.Defaulted = append(md.Defaulted, joinPath(prefix, f.name))
+		default:
+			md.Unset = append(md.Unset, joinPath(prefix, f.name))
+		}
+	}
+}
+
+// collectNested walks the value of the nested struct or slice of structs.
+func (m *Mapper) collectNested(md *Metadata, typ reflect.Type, value reflect.Value,
+	tagname, path string) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch {
+	case typ.Kind() == reflect.Struct && isStringMap(value) && nestedStruct(typ):
+		m.collectMetadata(md, typ, value, tagname, path)
+	case (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) &&
+		(value.Kind() == reflect.Slice || value.Kind() == reflect.Array):
+		for i := 0; i < value.Len(); i++ {
+			elem := value.Index(i)
+			if elem.Kind() == reflect.Interface {
+				elem = elem.Elem()
+			}
+			if !elem.IsValid() {
+				continue
+			}
+			m.collectNested(md, typ.Elem(), elem, tagname, path+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package smapping
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFillStructMetadata(t *testing.T) {
+	type endpoint struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type config struct {
+		Name      string     `json:"name"`
+		Primary   endpoint   `json:"primary"`
+		Fallback  *endpoint  `json:"fallback"`
+		Endpoints []endpoint `json:"endpoints"`
+		Debug     bool       `json:"debug"`
+	}
+	var cfg config
+	md, err := FillStructMetadata(&cfg, Mapped{
+		"name":      "service",
+		"primary":   Mapped{"host": "localhost", "prot": 80},
+		"fallback":  nil,
+		"endpoints": []interface{}{Mapped{"port": 81}, Mapped{"hots": "a"}},
+		"verbose":   true,
+	}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Metadata{
+		Keys: []string{"endpoints", "endpoints[0].port", "fallback",
+			"name", "primary", "primary.host"},
+		Unused: []string{"endpoints[1].hots", "primary.prot", "verbose"},
+		Unset: []string{"debug", "endpoints[0].host", "endpoints[1].host",
+			"endpoints[1].port", "fallback", "primary.port"},
+		Defaulted: []string{},
+	}
+	if !reflect.DeepEqual(md, expected) {
+		t.Errorf("expected %#v, got %#v", expected, md)
+	}
+	if cfg.Name != "service" || cfg.Primary.Host != "localhost" ||
+		len(cfg.Endpoints) != 2 || cfg.Endpoints[0].Port != 81 {
+		t.Errorf("wrong filled config %#v", cfg)
+	}
+}
+
+func TestFillStructMetadata_defaults(t *testing.T) {
+	var cfg defaultsConfig
+	md, err := FillStructMetadata(&cfg, Mapped{
+		"host":   "example.com",
+		"limits": Mapped{"rate": 2.5},
+		"node":   Mapped{},
+	}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Metadata{
+		Keys:   []string{"host", "limits", "limits.rate", "node"},
+		Unused: []string{},
+		Unset:  []string{"backup", "node.next", "plain"},
+		Defaulted: []string{"debug", "limits.burst", "node.name", "port",
+			"ports", "since", "tags", "timeout"},
+	}
+	if !reflect.DeepEqual(md, expected) {
+		t.Errorf("expected %#v, got %#v", expected, md)
+	}
+	if cfg.Host != "example.com" || cfg.Limits.Burst != 10 || cfg.Limits.Rate != 2.5 ||
+		cfg.Node.Name != "leaf" || !cfg.Debug {
+		t.Errorf("wrong filled config %#v", cfg)
+	}
+}
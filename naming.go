@@ -0,0 +1,93 @@
+package smapping
+
+import (
+	"reflect"
+	s "strings"
+	"unicode"
+)
+
+/*
+NamingStrategy converts the field name into the key of the field without
+tag name, see Config.KeyNaming. SnakeCase, CamelCase, KebabCase and
+ScreamingSnakeCase are provided.
+*/
+type NamingStrategy func(name string) string
+
+/*
+splitWords splits the name into words at the underscores, hyphens and
+spaces, and at the case changes keeping the acronyms together,
+e.g. "HTTPServerID" into "HTTP", "Server" and "ID". The digits stay
+with the preceding word.
+*/
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			words = append(words, string(runes[start:end]))
+		}
+		start = end
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			flush(i)
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				unicode.IsUpper(prev) && nextLower {
+				flush(i)
+			}
+		}
+	}
+	flush(len(runes))
+	return words
+}
+
+// SnakeCase converts the name into snake_case, e.g. "UserID" to "user_id".
+func SnakeCase(name string) string {
+	return s.ToLower(s.Join(splitWords(name), "_"))
+}
+
+// ScreamingSnakeCase converts the name into SCREAMING_SNAKE_CASE,
+// e.g. "UserID" to "USER_ID".
+func ScreamingSnakeCase(name string) string {
+	return s.ToUpper(s.Join(splitWords(name), "_"))
+}
+
+// KebabCase converts the name into kebab-case, e.g. "UserID" to "user-id".
+func KebabCase(name string) string {
+	return s.ToLower(s.Join(splitWords(name), "-"))
+}
+
+// CamelCase converts the name into camelCase, e.g. "UserID" to "userId".
+func CamelCase(name string) string {
+	words := splitWords(name)
+	for i, w := range words {
+		w = s.ToLower(w)
+		if i > 0 {
+			runes := []rune(w)
+			runes[0] = unicode.ToUpper(runes[0])
+			w = string(runes)
+		}
+		words[i] = w
+	}
+	return s.Join(words, "")
+}
+
+// outputKey converts the key of the field of the struct typ at index
+// with MapKeyFunc and MapKeyNaming.
+func (m *Mapper) outputKey(typ reflect.Type, index []int, key string) string {
+	if fn := m.config.MapKeyFunc; fn != nil {
+		if out := fn(typ.FieldByIndex(index)); out != "" {
+			return out
+		}
+	}
+	if naming := m.config.MapKeyNaming; naming != nil {
+		return naming(key)
+	}
+	return key
+}
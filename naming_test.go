@@ -0,0 +1,113 @@
+package smapping
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamingStrategy(t *testing.T) {
+	cases := []struct {
+		name                           string
+		snake, screaming, kebab, camel string
+	}{
+		{"UserID", "user_id", "USER_ID", "user-id", "userId"},
+		{"HTTPServerAddr", "http_server_addr", "HTTP_SERVER_ADDR", "http-server-addr", "httpServerAddr"},
+		{"userId", "user_id", "USER_ID", "user-id", "userId"},
+		{"user_id", "user_id", "USER_ID", "user-id", "userId"},
+		{"Field2Name", "field2_name", "FIELD2_NAME", "field2-name", "field2Name"},
+		{"X", "x", "X", "x", "x"},
+	}
+	for _, c := range cases {
+		got := []string{SnakeCase(c.name), ScreamingSnakeCase(c.name),
+			KebabCase(c.name), CamelCase(c.name)}
+		expected := []string{c.snake, c.screaming, c.kebab, c.camel}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, expected, got)
+		}
+	}
+}
+
+func TestMapper_keyNaming(t *testing.T) {
+	type account struct {
+		UserID    int
+		FirstName string
+		Email     string `json:"mail"`
+	}
+	mapper := NewMapper(Config{KeyNaming: SnakeCase})
+	acc := account{UserID: 1, FirstName: "Ann", Email: "ann@example.com"}
+	expected := Mapped{"user_id": 1, "first_name": "Ann", "email": "ann@example.com"}
+	if mapped := mapper.MapTags(acc); !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("expected %v, got %v", expected, mapped)
+	}
+
+	for _, mapped := range []Mapped{
+		{"user_id": 2, "first_name": "Bob"},
+		{"userId": 2, "firstName": "Bob"},
+		{"UserID": 2, "FirstName": "Bob"},
+		{"user-id": 2, "first-name": "Bob"},
+	} {
+		var got account
+		if err := mapper.FillStruct(&got, mapped); err != nil {
+			t.Fatal(err)
+		}
+		if got.UserID != 2 || got.FirstName != "Bob" {
+			t.Errorf("%v: wrong filled %#v", mapped, got)
+		}
+	}
+
+	mapper = NewMapper(Config{TagName: "json", KeyNaming: CamelCase, CaseInsensitive: true})
+	var got account
+	if err := mapper.FillStruct(&got, Mapped{"MAIL": "bob@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "bob@example.com" {
+		t.Errorf("expected the tagged key matched case insensitively, got %#v", got)
+	}
+}
+
+func TestMapper_mapKeyNaming(t *testing.T) {
+	type line struct {
+		ItemName string `json:"item_name"`
+		Qty      int    `json:"qty"`
+	}
+	type order struct {
+		OrderID string            `json:"order_id"`
+		Lines   []line            `json:"lines"`
+		Extra   map[string]string `json:"extra_data"`
+		Billing *line             `json:"billing_line"`
+	}
+	obj := order{
+		OrderID: "o1",
+		Lines:   []line{{ItemName: "pen", Qty: 2}},
+		Extra:   map[string]string{"gift_wrap": "yes"},
+		Billing: &line{ItemName: "fee", Qty: 1},
+	}
+	camel := NewMapper(Config{TagName: "json", MapKeyNaming: CamelCase})
+	expected := Mapped{
+		"orderId":     "o1",
+		"lines":       []interface{}{Mapped{"itemName": "pen", "qty": 2}},
+		"extraData":   Mapped{"gift_wrap": "yes"},
+		"billingLine": Mapped{"itemName": "fee", "qty": 1},
+	}
+	if mapped := camel.MapTags(obj); !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("expected %#v, got %#v", expected, mapped)
+	}
+
+	custom := NewMapper(Config{
+		MapKeyNaming: KebabCase,
+		MapKeyFunc: func(field reflect.StructField) string {
+			if field.Name == "Qty" {
+				return "quantity"
+			}
+			return ""
+		},
+	})
+	expected = Mapped{"item-name": "pen", "quantity": 2}
+	if mapped := custom.MapTags(obj.Lines[0]); !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("expected %#v, got %#v", expected, mapped)
+	}
+	flat := camel.MapTagsFlatten(obj.Lines[0])
+	if !reflect.DeepEqual(flat, Mapped{"itemName": "pen", "qty": 2}) {
+		t.Errorf("wrong flattened keys %v", flat)
+	}
+}
@@ -0,0 +1,232 @@
+package smapping
+
+import (
+	"reflect"
+	"sync"
+)
+
+// EncodeFunc encodes the value of the registered type into the value
+// put in Mapped by MapTags.
+type EncodeFunc func(value interface{}) (interface{}, error)
+
+// DecodeFunc decodes the provided value from Mapped into the value of
+// the registered type for FillStruct. Returning nil leaves the field
+// untouched.
+type DecodeFunc func(value interface{}) (interface{}, error)
+
+// decKey is the pair of the value type and the field type of the
+// registered decoder, the nil from matches any value type.
+type decKey struct {
+	from, to reflect.Type
+}
+
+// registry keeps the registered encoders and decoders of a Mapper.
+type registry struct {
+	mu       sync.RWMutex
+	encoders map[reflect.Type]EncodeFunc
+	decoders map[decKey]DecodeFunc
+	// ifaces are the registered interface types in registration order.
+	encIfaces  []reflect.Type
+	decIfaces  []reflect.Type
+	fromIfaces []reflect.Type
+	// validators are the custom rules of the validate tag by name.
+	validators map[string]ValidatorFunc
+}
+
+/*
+RegisterEncoder registers the encode function of the type typ used by
+MapTags before the built-in rules, including MapEncoder. When typ is an
+interface type, it's used for the types implementing it, which are
+passed to fn as the interface. The exact type takes precedence and then
+the interfaces in the registration order.
+The encoders are keyed by the source type only since the values of
+Mapped have no destination type, unlike RegisterDecoderFrom.
+*/
+func (m *Mapper) RegisterEncoder(typ reflect.Type, fn EncodeFunc) {
+	r := &m.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.encoders == nil {
+		r.encoders = make(map[reflect.Type]EncodeFunc)
+	}
+	if _, ok := r.encoders[typ]; !ok && typ.Kind() == reflect.Interface {
+		r.encIfaces = append(r.encIfaces, typ)
+	}
+	r.encoders[typ] = fn
+	m.resetConverters()
+}
+
+/*
+RegisterDecoder registers the decode function of the type typ used by
+FillStruct before the built-in rules, including MapDecoder and
+DecodeHook result conversion, for the values of any type. The value
+returned by fn must be assignable to the field type. The interface
+types are matched just like RegisterEncoder.
+*/
+func (m *Mapper) RegisterDecoder(typ reflect.Type, fn DecodeFunc) {
+	m.RegisterDecoderFrom(nil, typ, fn)
+}
+
+/*
+RegisterDecoderFrom registers the decode function of the values of
+type from into the fields of type to, e.g. the string and the float64
+decoded into decimal.Decimal can be registered separately. Either type
+can be an interface type. The decoders of the exact value type take
+precedence, then the interfaces of value type in the registration order
+and then the decoders of RegisterDecoder for any value type.
+*/
+func (m *Mapper) RegisterDecoderFrom(from, to reflect.Type, fn DecodeFunc) {
+	r := &m.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.decoders == nil {
+		r.decoders = make(map[decKey]DecodeFunc)
+	}
+	if !r.hasDecoderTo(to) && to.Kind() == reflect.Interface {
+		r.decIfaces = append(r.decIfaces, to)
+	}
+	if from != nil && from.Kind() == reflect.Interface && !r.hasDecoderFrom(from) {
+		r.fromIfaces = append(r.fromIfaces, from)
+	}
+	r.decoders[decKey{from: from, to: to}] = fn
+	m.resetConverters()
+}
+
+func (r *registry) hasDecoderTo(to reflect.Type) bool {
+	for key := range r.decoders {
+		if key.to == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *registry) hasDecoderFrom(from reflect.Type) bool {
+	for key := range r.decoders {
+		if key.from == from {
+			return true
+		}
+	}
+	return false
+}
+
+// resetConverters drops the compiled converters which may have the
+// field pairs converted without the registered functions.
+func (m *Mapper) resetConverters() {
+	m.converters.Range(func(key, _ interface{}) bool {
+		m.converters.Delete(key)
+		return true
+	})
+}
+
+// encoderOf returns the registered encoder of typ with the interface
+// type it's registered for, or nil when there's none.
+func (m *Mapper) encoderOf(typ reflect.Type) (EncodeFunc, reflect.Type) {
+	r := &m.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fn, ok := r.encoders[typ]; ok {
+		return fn, typ
+	}
+	for _, iface := range r.encIfaces {
+		if implements(typ, iface) {
+			return r.encoders[iface], iface
+		}
+	}
+	return nil, nil
+}
+
+// decoderOf returns the registered decoder of the values of type from
+// into typ or nil, the nil from only matches the decoders for any
+// value type.
+func (m *Mapper) decoderOf(from, typ reflect.Type) DecodeFunc {
+	r := &m.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.decoders) == 0 {
+		return nil
+	}
+	froms := []reflect.Type{from}
+	if from != nil {
+		for _, iface := range r.fromIfaces {
+			if implements(from, iface) {
+				froms = append(froms, iface)
+			}
+		}
+		froms = append(froms, nil)
+	}
+	for _, from := range froms {
+		if fn, ok := r.decoders[decKey{from: from, to: typ}]; ok {
+			return fn
+		}
+		for _, iface := range r.decIfaces {
+			if implements(typ, iface) {
+				if fn, ok := r.decoders[decKey{from: from, to: iface}]; ok {
+					return fn
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasDecoder reports whether typ has the registered decoder of any
+// value type.
+func (m *Mapper) hasDecoder(typ reflect.Type) bool {
+	r := &m.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for key := range r.decoders {
+		if key.to == typ || key.to.Kind() == reflect.Interface && implements(typ, key.to) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeRegistered encodes fieldval with the registered encoder, it's
+// not ok when there's none.
+func (m *Mapper) encodeRegistered(fieldval reflect.Value) (interface{}, bool, error) {
+	fn, typ := m.encoderOf(fieldval.Type())
+	if fn == nil {
+		return nil, false, nil
+	}
+	value := fieldval.Interface()
+	if typ.Kind() == reflect.Interface {
+		value, _ = asInterface(fieldval, typ)
+	}
+	val, err := fn(value)
+	return val, true, err
+}
+
+// decodeRegistered decodes value into typ with the registered decoder.
+func decodeRegistered(fn DecodeFunc, typ reflect.Type, value interface{}) (reflect.Value, bool, error) {
+	decoded, err := fn(value)
+	if err != nil {
+		return reflect.Value{}, false, err
+	}
+	val := reflect.ValueOf(decoded)
+	if !val.IsValid() {
+		return val, false, nil
+	}
+	if !val.Type().AssignableTo(typ) {
+		return val, false, ErrTypeNotMatch
+	}
+	return val, true, nil
+}
+
+// hasRegistered reports whether src has the registered encoder or dst
+// has the registered decoder.
+func (m *Mapper) hasRegistered(dst, src reflect.Type) bool {
+	r := &m.registry
+	r.mu.RLock()
+	empty := len(r.encoders) == 0 && len(r.decoders) == 0
+	r.mu.RUnlock()
+	if empty {
+		return false
+	}
+	if fn, _ := m.encoderOf(src); fn != nil {
+		return true
+	}
+	return m.hasDecoder(dst)
+}
@@ -0,0 +1,137 @@
+package smapping
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func ExampleMapper_RegisterDecoder() {
+	type account struct {
+		Name sql.NullString `json:"name"`
+	}
+	nullString := reflect.TypeOf(sql.NullString{})
+	mapper := NewMapper(Config{TagName: "json"})
+	mapper.RegisterEncoder(nullString, func(value interface{}) (interface{}, error) {
+		if ns := value.(sql.NullString); ns.Valid {
+			return ns.String, nil
+		}
+		return nil, nil
+	})
+	mapper.RegisterDecoder(nullString, func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		return sql.NullString{String: s, Valid: ok}, nil
+	})
+	var acc account
+	err := mapper.FillStruct(&acc, Mapped{"name": "smapping"})
+	fmt.Println(err, acc.Name.Valid, acc.Name.String)
+	fmt.Println(mapper.MapTags(&acc)["name"])
+
+	// Output:
+	// <nil> true smapping
+	// smapping
+}
+
+type stringerID int
+
+func (id stringerID) String() string {
+	return fmt.Sprintf("ID-%d", int(id))
+}
+
+func TestMapper_registryInterface(t *testing.T) {
+	type target struct {
+		ID    stringerID   `json:"id"`
+		IDs   []stringerID `json:"ids"`
+		Count int          `json:"count"`
+	}
+	mapper := NewMapper(Config{TagName: "json"})
+	mapper.RegisterEncoder(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+		func(value interface{}) (interface{}, error) {
+			return value.(fmt.Stringer).String(), nil
+		})
+	mapper.RegisterDecoder(reflect.TypeOf(stringerID(0)),
+		func(value interface{}) (interface{}, error) {
+			var id int
+			if _, err := fmt.Sscanf(value.(string), "ID-%d", &id); err != nil {
+				return nil, err
+			}
+			return stringerID(id), nil
+		})
+	obj := target{ID: 1, IDs: []stringerID{2, 3}, Count: 4}
+	mapped := mapper.MapTags(&obj)
+	expected := Mapped{"id": "ID-1", "ids": []interface{}{"ID-2", "ID-3"}, "count": 4}
+	if !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("expected %#v, got %#v", expected, mapped)
+	}
+	var filled target
+	if err := mapper.FillStruct(&filled, mapped); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filled, obj) {
+		t.Errorf("expected %#v, got %#v", obj, filled)
+	}
+
+	var converted target
+	if err := mapper.Convert(&converted, &obj); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(converted, obj) {
+		t.Errorf("expected %#v, got %#v", obj, converted)
+	}
+
+	err := mapper.FillStruct(&filled, Mapped{"id": "1"})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "id" ||
+		!strings.Contains(err.Error(), "input does not match format") {
+		t.Errorf("expected decoder error at id, got %v", err)
+	}
+	if _, err := FillNew[target](Mapped{"id": "ID-1"}, "json"); err == nil {
+		t.Errorf("expected the default mapper without the registry to fail")
+	}
+}
+
+type cents int64
+
+func TestMapper_registerDecoderFrom(t *testing.T) {
+	type price struct {
+		Amount cents   `json:"amount"`
+		Items  []cents `json:"items"`
+	}
+	centsType := reflect.TypeOf(cents(0))
+	mapper := NewMapper(Config{TagName: "json"})
+	mapper.RegisterDecoderFrom(reflect.TypeOf(""), centsType,
+		func(value interface{}) (interface{}, error) {
+			var whole, frac int64
+			if _, err := fmt.Sscanf(value.(string), "%d.%d", &whole, &frac); err != nil {
+				return nil, err
+			}
+			return cents(whole*100 + frac), nil
+		})
+	mapper.RegisterDecoderFrom(reflect.TypeOf(0.0), centsType,
+		func(value interface{}) (interface{}, error) {
+			return cents(value.(float64)*100 + 0.5), nil
+		})
+	mapper.RegisterDecoderFrom(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), centsType,
+		func(value interface{}) (interface{}, error) {
+			return cents(len(value.(fmt.Stringer).String())), nil
+		})
+	mapper.RegisterDecoder(centsType, func(value interface{}) (interface{}, error) {
+		return cents(-1), nil
+	})
+
+	var p price
+	err := mapper.FillStruct(&p, Mapped{
+		"amount": "12.34",
+		"items":  []interface{}{1.5, stringerID(7), true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := price{Amount: 1234, Items: []cents{150, 4, -1}}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected %#v, got %#v", expected, p)
+	}
+}
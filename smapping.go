@@ -8,8 +8,10 @@ package smapping
 import (
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	s "strings"
 	"time"
 )
@@ -55,11 +57,102 @@ Now it's implemented as MapTags with empty tag "".
 Only map the exported fields.
 */
 func MapFields(x interface{}) Mapped {
-	return MapTags(x, "")
+	return defaultMapper.mapTags(x, "")
 }
 
-func tagHead(tag string) string {
-	return s.Split(tag, ",")[0]
+// tagOptions is the string following a comma in a struct field's tag,
+// or the empty string. The options are interpreted like encoding/json does.
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
+	if idx := s.Index(tag, ","); idx != -1 {
+		return tag[:idx], tagOptions(tag[idx+1:])
+	}
+	return tag, ""
+}
+
+// Contains reports whether a comma-separated list of options
+// contains a particular option.
+func (o tagOptions) Contains(option string) bool {
+	opts := string(o)
+	for opts != "" {
+		var next string
+		if idx := s.Index(opts, ","); idx >= 0 {
+			opts, next = opts[:idx], opts[idx+1:]
+		}
+		if opts == option {
+			return true
+		}
+		opts = next
+	}
+	return false
+}
+
+// omits reports whether the field value should be left out of
+// the Mapped because of the omitempty or omitzero option.
+func (o tagOptions) omits(v reflect.Value) bool {
+	return (o.Contains("omitempty") && isEmptyValue(v)) ||
+		(o.Contains("omitzero") && isZeroValue(v))
+}
+
+// fieldKey returns the key used for the field with the tag name.
+// The field is skipped when it has no such tag or the tag is "-".
+// The empty tag name means the field name is used as key just like
+// the tag without name e.g. `json:",omitempty"`.
+func fieldKey(field reflect.StructField, tag string) (string, tagOptions, bool) {
+	if tag == "" {
+		return field.Name, "", true
+	}
+	tagvalue, ok := field.Tag.Lookup(tag)
+	if !ok || tagvalue == "-" {
+		return "", "", false
+	}
+	name, opts := parseTag(tagvalue)
+	if name == "" {
+		name = field.Name
+	}
+	return name, opts, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerI = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// isZeroValue reports whether the value is zero, preferring the
+// IsZero method when the type has one, e.g. time.Time.
+func isZeroValue(v reflect.Value) bool {
+	if isValueNil(v) {
+		return true
+	}
+	if v.Type().Implements(isZeroerI) {
+		return v.Interface().(isZeroer).IsZero()
+	}
+	if reflect.PtrTo(v.Type()).Implements(isZeroerI) {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return ptr.Interface().(isZeroer).IsZero()
+	}
+	return v.IsZero()
 }
 
 func isValueNil(v reflect.Value) bool {
@@ -75,141 +168,191 @@ func isValueNil(v reflect.Value) bool {
 	return false
 }
 
-func getValTag(fieldval reflect.Value, tag string) interface{} {
+func (m *Mapper) getValTag(fieldval reflect.Value, tag string) interface{} {
+	resval, _ := m.getValTagE(fieldval, tag)
+	return resval
+}
+
+// getValTagE maps the field value and reports the failing encoders and
+// the unsupported values, the nested failures are reported as *MultiError.
+// The failing value is mapped as nil while the unsupported value is
+// copied as is.
+func (m *Mapper) getValTagE(fieldval reflect.Value, tag string) (interface{}, error) {
 	var resval interface{}
 	if isValueNil(fieldval) {
-		return nil
+		return nil, nil
 	}
-	if fieldval.Type().Name() == "Time" ||
-		reflect.Indirect(fieldval).Type().Name() == "Time" {
+	if val, ok, err := m.encodeRegistered(fieldval); ok {
+		return val, err
+	}
+	caps := capsOf(fieldval.Type())
+	if caps.isTime {
 		resval = fieldval.Interface()
-	} else if typof := fieldval.Type(); typof.Implements(mapEncoderI) ||
-		reflect.PtrTo(typof).Implements(mapEncoderI) {
-		valx, ok := fieldval.Interface().(MapEncoder)
+	} else if caps.encoder {
+		valx, ok := asInterface(fieldval, mapEncoderI)
 		if !ok {
-			return nil
+			return nil, nil
 		}
-		val, err := valx.MapEncode()
+		val, err := valx.(MapEncoder).MapEncode()
 		if err != nil {
-			val = nil
+			return nil, err
 		}
 		resval = val
+	} else if val, ok, err := m.encodedValue(fieldval, caps); ok {
+		return val, err
 	} else {
 		switch fieldval.Kind() {
 		case reflect.Struct:
-			resval = MapTags(fieldval, tag)
+			return m.mapTagsE(fieldval, tag)
+		case reflect.Map:
+			return m.mapValue(fieldval, tag)
 		case reflect.Ptr:
 			indirect := reflect.Indirect(fieldval)
 			if indirect.Kind() < reflect.Array || indirect.Kind() == reflect.String {
 				resval = indirect.Interface()
+			} else if indirect.Kind() != reflect.Struct {
+				return m.getValTagE(indirect, tag)
 			} else {
-				resval = MapTags(fieldval.Elem(), tag)
+				return m.mapTagsE(fieldval.Elem(), tag)
 			}
-		case reflect.Slice:
+		case reflect.Slice, reflect.Array:
+			errs := &MultiError{}
 			placeholder := make([]interface{}, fieldval.Len())
 			for i := 0; i < fieldval.Len(); i++ {
 				fieldvalidx := fieldval.Index(i)
-				theval := getValTag(fieldvalidx, tag)
+				theval, err := m.getValTagE(fieldvalidx, tag)
+				if err != nil {
+					index := "[" + strconv.Itoa(i) + "]"
+					errs.add(index, encodeError(fieldvalidx, tag, index, err))
+				}
 				placeholder[i] = theval
 			}
-			resval = placeholder
+			return placeholder, errs.errorOrNil()
+		case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			return fieldval.Interface(), ErrUnsupportedType
 		default:
 			resval = fieldval.Interface()
 		}
 
 	}
-	return resval
+	return resval, nil
+}
+
+// encodeError wraps err as *FieldError of the mapped value, the nested
+// *MultiError is returned as is.
+func encodeError(fieldval reflect.Value, tag, key string, err error) error {
+	if _, nested := err.(*MultiError); nested {
+		return err
+	}
+	return &FieldError{Key: key, Tag: tag, Type: fieldval.Type(),
+		Value: fieldval.Interface(), Err: err}
 }
 
 /*
 MapTags maps the tag value of defined field tag name. This enable
 various field extraction that will be mapped to mapped interfaces{}.
+The fields of embedded structs without tag name are promoted to the
+parent level just like encoding/json does.
+The field values are encoded with MapEncoder first and then with
+encoding.TextMarshaler as string, while FillStruct decodes them with
+MapDecoder first and then with encoding.TextUnmarshaler from string or
+bytes. The time.Time values are kept as is.
 */
 func MapTags(x interface{}, tag string) Mapped {
+	return defaultMapper.mapTags(x, tag)
+}
+
+/*
+MapTagsE is MapTags that reports the errors of MapEncoder and
+encoding.TextMarshaler together with the unsupported values such as
+channels, funcs and unsafe pointers as *MultiError of *FieldError with
+the path of the field. The Mapped is still returned with the failing
+values as nil and the unsupported values copied as is.
+*/
+func MapTagsE(x interface{}, tag string) (Mapped, error) {
+	return defaultMapper.mapTagsE(x, tag)
+}
+
+// MapFieldsE is MapFields that reports the errors just like MapTagsE.
+func MapFieldsE(x interface{}) (Mapped, error) {
+	return defaultMapper.mapTagsE(x, "")
+}
+
+func (m *Mapper) mapTags(x interface{}, tag string) Mapped {
+	result, _ := m.mapTagsE(x, tag)
+	return result
+}
+
+func (m *Mapper) mapTagsE(x interface{}, tag string) (Mapped, error) {
 	result := make(Mapped)
 	value := extractValue(x)
 	if !value.IsValid() {
-		return nil
+		return nil, nil
 	}
-	xtype := value.Type()
-	for i := 0; i < value.NumField(); i++ {
-		field := xtype.Field(i)
-		if field.PkgPath != "" {
+	errs := &MultiError{}
+	for _, f := range m.plan(value.Type(), tag).fields {
+		fieldval := fieldByIndex(value, f.index)
+		if !fieldval.IsValid() || f.opts.omits(fieldval) {
 			continue
 		}
-		fieldval := value.Field(i)
-		if tag == "" {
-			result[field.Name] = getValTag(fieldval, tag)
-		} else if tagvalue, ok := field.Tag.Lookup(tag); ok {
-			result[tagHead(tagvalue)] = getValTag(fieldval, tag)
+		key := f.out
+		if val, ok := timeValue(f, fieldval); ok {
+			result[key] = val
+			continue
+		}
+		if val, ok := quotedValue(f, fieldval); ok {
+			result[key] = val
+			continue
 		}
+		val, err := m.getValTagE(fieldval, tag)
+		if err != nil {
+			errs.add(key, encodeError(fieldval, tag, key, err))
+		}
+		result[key] = val
 	}
-	return result
+	return result, errs.errorOrNil()
 }
 
 /*
 MapTagsWithDefault maps the tag with optional fallback tags. This to enable
 tag differences when there are only few difference with the default “json“
-tag.
+tag. The fallback tags are looked up for the nested structs too.
 */
 func MapTagsWithDefault(x interface{}, tag string, defs ...string) Mapped {
-	result := make(Mapped)
-	value := extractValue(x)
-	if !value.IsValid() {
-		return nil
-	}
-	xtype := value.Type()
-	for i := 0; i < value.NumField(); i++ {
-		field := xtype.Field(i)
-		if field.PkgPath != "" {
-			continue
-		}
-		var (
-			tagval string
-			ok     bool
-		)
-		if tagval, ok = field.Tag.Lookup(tag); ok {
-			result[tagHead(tagval)] = getValTag(value.Field(i), tag)
-		} else {
-			for _, deftag := range defs {
-				if tagval, ok = field.Tag.Lookup(deftag); ok {
-					result[tagHead(tagval)] = getValTag(value.Field(i), deftag)
-					break // break from looping the defs
-				}
-			}
-		}
-	}
-	return result
+	return fallbackMapper(tag, defs).MapTags(x)
 }
 
 // MapTagsFlatten is to flatten mapped object with specific tag. The limitation
 // of this flattening that it can't have duplicate tag name and it will give
 // incorrect result because the older value will be written with newer map field value.
+// Use MapTagsFlattenSep for the prefixed keys without such limitation.
 func MapTagsFlatten(x interface{}, tag string) Mapped {
+	return defaultMapper.mapTagsFlatten(x, tag)
+}
+
+func (m *Mapper) mapTagsFlatten(x interface{}, tag string) Mapped {
 	result := make(Mapped)
 	value := extractValue(x)
 	if !value.IsValid() {
 		return nil
 	}
-	xtype := value.Type()
-	for i := 0; i < value.NumField(); i++ {
-		field := xtype.Field(i)
-		if field.PkgPath != "" {
+	plan := m.plan(value.Type(), tag)
+	for _, f := range plan.fields {
+		// only the tagged fields are put, the nested structs are walked
+		if f.tag == "" || nestedStruct(f.typ) {
 			continue
 		}
-		fieldval := value.Field(i)
-		isStruct := reflect.Indirect(fieldval).Type().Kind() == reflect.Struct
-		if tagvalue, ok := field.Tag.Lookup(tag); ok && !isStruct {
-			key := tagHead(tagvalue)
-			result[key] = fieldval.Interface()
-			continue
+		fieldval := fieldByIndex(value, f.index)
+		if fieldval.IsValid() && !f.opts.omits(fieldval) {
+			result[f.out] = fieldval.Interface()
 		}
-		fieldval = reflect.Indirect(fieldval)
-		if !isStruct {
+	}
+	for _, f := range plan.structs {
+		fieldval := fieldByIndex(value, f.index)
+		if !fieldval.IsValid() || isValueNil(fieldval) {
 			continue
 		}
-		nests := MapTagsFlatten(fieldval, tag)
-		for k, v := range nests {
+		for k, v := range m.mapTagsFlatten(reflect.Indirect(fieldval), tag) {
 			result[k] = v
 		}
 	}
@@ -219,69 +362,56 @@ func MapTagsFlatten(x interface{}, tag string) Mapped {
 func isTime(typ reflect.Type) bool {
 	return typ.Name() == "Time" || typ.String() == "*time.Time"
 }
-func handleTime(layout, format string, typ reflect.Type) (reflect.Value, error) {
-	t, err := time.Parse(layout, format)
-	var resval reflect.Value
-	if err != nil {
-		return resval, fmt.Errorf("time conversion: %s", err.Error())
-	}
-	if typ.Kind() == reflect.Ptr {
-		resval = reflect.New(typ).Elem()
-		resval.Set(reflect.ValueOf(&t))
-	} else {
-		resval = reflect.ValueOf(&t).Elem()
+func isSlicedObj(val reflect.Value, typ reflect.Type) bool {
+	return (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) &&
+		(typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array)
+}
 
-	}
-	return resval, err
+// isStringMap reports whether val is a map with string keys e.g. Mapped,
+// map[string]interface{} or map[string]string.
+func isStringMap(val reflect.Value) bool {
+	return val.Kind() == reflect.Map && val.Type().Key().Kind() == reflect.String
 }
 
-func isSlicedObj(val, res reflect.Value) bool {
-	return val.Type().Kind() == reflect.Slice &&
-		res.Kind() == reflect.Slice
+// isStructType reports whether typ is a struct or a pointer to struct.
+func isStructType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct
 }
 
-func fillMapIter(vfield, res reflect.Value, val *reflect.Value, tagname string) error {
+// stringMapped returns the map with string keys val as Mapped.
+func stringMapped(val reflect.Value) Mapped {
+	if nested, ok := val.Interface().(Mapped); ok {
+		return nested
+	}
+	nested := make(Mapped, val.Len())
 	iter := val.MapRange()
-	m := Mapped{}
 	for iter.Next() {
-		m[iter.Key().String()] = iter.Value().Interface()
+		nested[iter.Key().String()] = iter.Value().Interface()
 	}
-	if vfield.Kind() == reflect.Ptr {
-		vval := vfield.Type().Elem()
-		ptrres := reflect.New(vval).Elem()
-		mapf := make(map[string]reflect.StructField)
-		if tagname != "" {
-			populateMapFieldsTag(mapf, tagname, ptrres)
-		}
-		for k, v := range m {
-			_, err := setFieldFromTag(ptrres, tagname, k, v, mapf)
-			if err != nil {
-				return fmt.Errorf("ptr nested error: %s", err.Error())
-			}
+	return nested
+}
+
+func (m *Mapper) fillMapIter(typ reflect.Type, val *reflect.Value, tagname string) error {
+	nested := stringMapped(*val)
+	if typ.Kind() == reflect.Ptr {
+		ptrres := reflect.New(typ.Elem()).Elem()
+		if err := m.fillStruct(ptrres, nested, tagname); err != nil {
+			return err
 		}
 		*val = ptrres.Addr()
 	} else {
-		if err := FillStructByTags(res, m, tagname); err != nil {
-			return fmt.Errorf("nested error: %s", err.Error())
+		res := reflect.New(typ).Elem()
+		if err := m.fillStruct(res, nested, tagname); err != nil {
+			return err
 		}
 		*val = res
 	}
 	return nil
 }
 
-func fillTime(vfield reflect.Value, val *reflect.Value) error {
-	if (*val).Type().Name() == "string" {
-		newval, err := handleTime(time.RFC3339, val.String(), vfield.Type())
-		if err != nil {
-			return fmt.Errorf("smapping Time conversion: %s", err.Error())
-		}
-		*val = newval
-	} else if val.Type().Name() == "Time" {
-		*val = reflect.Indirect(*val)
-	}
-	return nil
-}
-
 func scalarType(val reflect.Value) bool {
 	if val.Kind() != reflect.Interface {
 		return false
@@ -289,126 +419,166 @@ func scalarType(val reflect.Value) bool {
 	switch val.Interface().(type) {
 	case int, int8, int16, int32, int64,
 		uint, uint8, uint16, uint32, uint64,
-		float32, float64, string, []byte:
+		float32, float64, string, []byte, bool, json.Number:
 		return true
 
 	}
 	return false
 }
 
-func ptrExtract(vval, rval reflect.Value) (reflect.Value, bool) {
-	acttype := rval.Type().Elem()
-	newrval := reflect.New(acttype).Elem()
-	gotval := false
-	if newrval.Kind() < reflect.Array {
-		gotval = true
-		ival := vval.Interface()
-		if newrval.Kind() > reflect.Bool && newrval.Kind() < reflect.Uint {
-			nval := reflect.ValueOf(ival).Int()
-			newrval.SetInt(nval)
-		} else if newrval.Kind() > reflect.Uintptr &&
-			newrval.Kind() < reflect.Complex64 {
-			fval := reflect.ValueOf(ival).Float()
-			newrval.SetFloat(fval)
-		} else {
-			newrval.Set(reflect.ValueOf(ival))
-		}
+// fillSlice fills the new slice or array of typ with the elements of val
+// and reports the failing elements as *MultiError with the index as the
+// path. The array must have the same length as val.
+func (m *Mapper) fillSlice(typ reflect.Type, val *reflect.Value, tagname string) error {
+	if typ.Kind() == reflect.Array {
+		if val.Len() != typ.Len() {
+			return fmt.Errorf("array length mismatch: expected %d elements, got %d",
+				typ.Len(), val.Len())
+		}
+		sliced := reflect.ValueOf(val.Interface())
+		if err := m.fillSlice(reflect.SliceOf(typ.Elem()), &sliced, tagname); err != nil {
+			return err
+		}
+		res := reflect.New(typ).Elem()
+		reflect.Copy(res, sliced)
+		*val = res
+		return nil
 	}
-	return newrval, gotval
-}
-
-func fillSlice(res reflect.Value, val *reflect.Value, tagname string) error {
+	errs := &MultiError{}
+	res := reflect.MakeSlice(typ, 0, val.Len())
+	elemtyp := typ.Elem()
+	elem := typeField(elemtyp)
+	// the elements with their own decoding are converted like the fields
+	decodable := elem.caps.decoder || elem.caps.isTime || elem.caps.textUnmarshaler ||
+		m.config.JSONCodec && elem.caps.jsonUnmarshaler || m.hasDecoder(elemtyp)
+	hooked := m.config.DecodeHook != nil
 	for i := 0; i < val.Len(); i++ {
 		vval := val.Index(i)
-		rval := reflect.New(res.Type().Elem()).Elem()
-		if vval.Kind() < reflect.Array {
-			rval.Set(vval)
-			res = reflect.Append(res, rval)
-			continue
-		} else if scalarType(vval) {
-			if rval.Kind() == reflect.Ptr {
-				if newrval, ok := ptrExtract(vval, rval); ok {
-					res = reflect.Append(res, newrval.Addr())
-					continue
+		index := "[" + strconv.Itoa(i) + "]"
+		scalar := vval.Kind() < reflect.Array || vval.Kind() == reflect.String ||
+			scalarType(vval)
+		if !decodable && !hooked && scalar {
+			newrval, err := scalarValue(vval, elemtyp, m.config.WeaklyTyped)
+			if err != nil {
+				errs.add(index, &FieldError{Key: index, Tag: tagname,
+					Type: elemtyp, Value: vval.Interface(), Err: err})
+				if m.config.ErrorMode == ErrorFailFast {
+					return errs
 				}
+				continue
 			}
-			rval.Set(reflect.ValueOf(vval.Interface()))
-			res = reflect.Append(res, rval)
+			res = reflect.Append(res, newrval)
 			continue
-		} else if vval.IsNil() {
-			res = reflect.Append(res, reflect.Zero(rval.Type()))
+		} else if isValueNil(vval) {
+			res = reflect.Append(res, reflect.Zero(elemtyp))
 			continue
-		}
-		newrval := rval
-		if rval.Kind() == reflect.Ptr {
-			var ok bool
-			if newrval, ok = ptrExtract(vval, rval); ok {
-				res = reflect.Append(res, newrval.Addr())
+		} else if decodable || scalar || !isStructType(elemtyp) {
+			// the scalars go through DecodeHook with the fields
+			newrval, ok, err := m.fieldValue(elem, tagname, index, vval.Interface())
+			if err != nil {
+				errs.add(index, err)
+				if m.config.ErrorMode == ErrorFailFast {
+					return errs
+				}
 				continue
+			} else if !ok {
+				newrval = reflect.Zero(elemtyp)
 			}
+			res = reflect.Append(res, newrval)
+			continue
 		}
-		m, ok := vval.Interface().(Mapped)
-		if !ok && newrval.Kind() >= reflect.Array {
-			m = MapTags(vval.Interface(), tagname)
+		var newrval reflect.Value
+		if elemtyp.Kind() == reflect.Ptr {
+			newrval = reflect.New(elemtyp.Elem()).Elem()
+		} else {
+			newrval = reflect.New(elemtyp).Elem()
 		}
-		err := FillStructByTags(newrval, m, tagname)
-		if err != nil {
-			return fmt.Errorf("cannot set an element slice")
+		var nested Mapped
+		if elem := reflect.ValueOf(vval.Interface()); isStringMap(elem) {
+			nested = stringMapped(elem)
+		} else {
+			nested = m.mapTags(vval.Interface(), tagname)
+		}
+		if err := m.fillStruct(newrval, nested, tagname); err != nil {
+			errs.add(index, err)
+			if m.config.ErrorMode == ErrorFailFast {
+				return errs
+			}
 		}
-		if rval.Kind() == reflect.Ptr {
+		if elemtyp.Kind() == reflect.Ptr {
 			res = reflect.Append(res, newrval.Addr())
 		} else {
 			res = reflect.Append(res, newrval)
 		}
 	}
 	*val = res
-	return nil
+	return errs.errorOrNil()
 }
 
-func populateMapFieldsTag(mapfield map[string]reflect.StructField, tagname string, obj interface{}) {
-	sval := extractValue(obj)
-	stype := sval.Type()
-	for i := 0; i < sval.NumField(); i++ {
-		field := stype.Field(i)
-		if field.PkgPath != "" {
-			continue
-		}
-		if tag, ok := field.Tag.Lookup(tagname); ok {
-			mapfield[tagHead(tag)] = field
-		}
+// lookupField returns the field of the key, falling back to the key
+// converted with KeyNaming and then to the lower cased key when the
+// Mapper is case insensitive.
+func (m *Mapper) lookupField(mapfield map[string]field, key string) (field, bool) {
+	field, ok := mapfield[key]
+	if !ok && m.config.KeyNaming != nil {
+		key = m.config.KeyNaming(key)
+		field, ok = mapfield[key]
+	}
+	if !ok && m.config.CaseInsensitive {
+		field, ok = mapfield[s.ToLower(key)]
 	}
+	return field, ok
 }
 
-func setFieldFromTag(obj interface{}, tagname, tagvalue string,
-	value interface{}, mapfield map[string]reflect.StructField) (bool, error) {
-	sval := extractValue(obj)
-	stype := sval.Type()
-	var (
-		vfield reflect.Value
-		field  reflect.StructField
-	)
-	if tagname == "" {
-		vfield = sval.FieldByName(tagvalue)
-		var fieldok bool
-		field, fieldok = stype.FieldByName(tagvalue)
-		if !fieldok {
-			return false, nil
-		}
-	} else {
-		var fieldok bool
-		field, fieldok = mapfield[tagvalue]
-		if !fieldok {
-			return false, nil
-		}
-		vfield = sval.FieldByName(field.Name)
+func (m *Mapper) setFieldFromTag(obj reflect.Value, tagname, tagvalue string,
+	value interface{}, field field) (bool, error) {
+	val, ok, err := m.fieldValue(field, tagname, tagvalue, value)
+	if !ok || err != nil {
+		return false, err
 	}
+	// the embedded pointers are only allocated once the value is converted
+	fieldByIndexAlloc(obj, field.index).Set(val)
+	return true, nil
+}
+
+// fieldError wraps err as *FieldError of the field, the nested
+// *MultiError is returned as is.
+func fieldError(field field, tagname, key string, value interface{}, err error) error {
+	if _, nested := err.(*MultiError); nested {
+		return err
+	}
+	return &FieldError{Key: key, Tag: tagname,
+		Type: field.typ, Value: value, Err: err}
+}
+
+// fieldValue converts the value of the key to the type of field.
+// It's not ok when there's nothing to set.
+func (m *Mapper) fieldValue(field field, tagname, key string,
+	value interface{}) (reflect.Value, bool, error) {
 	val := reflect.ValueOf(value)
 	if !val.IsValid() {
-		return false, nil
+		return val, false, nil
+	}
+	fail := func(err error) (reflect.Value, bool, error) {
+		return reflect.Value{}, false, fieldError(field, tagname, key, value, err)
+	}
+	if hook := m.config.DecodeHook; hook != nil {
+		var err error
+		if value, err = hook(val.Type(), field.typ, value); err != nil {
+			return fail(err)
+		}
+		if val = reflect.ValueOf(value); !val.IsValid() {
+			return val, false, nil
+		}
 	}
-	res := reflect.New(vfield.Type()).Elem()
-	if typof := vfield.Type(); typof.Implements(mapDecoderI) ||
-		reflect.PtrTo(typof).Implements(mapDecoderI) {
+	typof := field.typ
+	if decode := m.decoderOf(val.Type(), typof); decode != nil {
+		nval, ok, err := decodeRegistered(decode, typof, value)
+		if err != nil {
+			return fail(err)
+		}
+		return nval, ok, nil
+	} else if field.caps.decoder {
 		isPtr := typof.Kind() == reflect.Ptr
 		var mapval reflect.Value
 		if isPtr {
@@ -416,145 +586,216 @@ func setFieldFromTag(obj interface{}, tagname, tagvalue string,
 		} else {
 			mapval = reflect.New(typof)
 		}
+		// the value receiver can only decode into the allocated map
+		if mapval.Elem().Kind() == reflect.Map {
+			mapval.Elem().Set(reflect.MakeMap(mapval.Elem().Type()))
+		}
 		mapdecoder, ok := mapval.Interface().(MapDecoder)
 		if !ok {
-			return false, nil
+			return reflect.Value{}, false, nil
 		}
 		if err := mapdecoder.MapDecode(value); err != nil {
-			return false, err
+			return fail(err)
 		}
 		if isPtr {
 			val = reflect.ValueOf(mapdecoder)
 		} else {
 			val = reflect.Indirect(reflect.ValueOf(mapdecoder))
 		}
-	} else if isTime(vfield.Type()) {
-		if err := fillTime(vfield, &val); err != nil {
-			return false, err
-		}
-	} else if res.IsValid() && val.Type().Name() == "Mapped" {
-		if err := fillMapIter(vfield, res, &val, tagname); err != nil {
-			return false, err
-		}
-	} else if isSlicedObj(val, res) {
-		if err := fillSlice(res, &val, tagname); err != nil {
-			return false, err
-		}
-	} else if vfield.Kind() == reflect.Ptr {
-		vfv := vfield.Type().Elem()
-		if vfv != val.Type() {
-			return false, fmt.Errorf(
-				"provided value (%#v) pointer type %T not match field tag '%s' of tagname '%s' of type '%v' from object",
-				value, value, tagname, tagvalue, field.Type)
-		}
-		nval := reflect.New(vfv).Elem()
-		nval.Set(val)
-		val = nval.Addr()
-	} else if field.Type != val.Type() {
-		return false, fmt.Errorf("provided value (%#v) type %T not match field tag '%s' of tagname '%s'  of type '%v' from object",
-			value, value, tagname, tagvalue, field.Type)
-	}
-	vfield.Set(val)
-	return true, nil
+	} else if isTime(typof) {
+		if err := m.fillTime(field, &val); err != nil {
+			return fail(err)
+		}
+	} else if field.caps.textUnmarshaler && isTextValue(val) &&
+		!val.Type().AssignableTo(typof) {
+		nval, err := textValue(typof, val)
+		if err != nil {
+			return fail(err)
+		}
+		val = nval
+	} else if m.config.JSONCodec && field.caps.jsonUnmarshaler &&
+		!val.Type().AssignableTo(typof) {
+		nval, err := jsonValue(typof, value)
+		if err != nil {
+			return fail(err)
+		}
+		val = nval
+	} else if typof.Kind() == reflect.Map && val.Kind() == reflect.Map {
+		if err := m.fillMap(typof, &val, tagname); err != nil {
+			return fail(err)
+		}
+	} else if isStringMap(val) && isStructType(typof) {
+		if err := m.fillMapIter(typof, &val, tagname); err != nil {
+			return fail(err)
+		}
+	} else if isSlicedObj(val, typof) {
+		if err := m.fillSlice(typof, &val, tagname); err != nil {
+			return fail(err)
+		}
+	} else if val.Kind() == reflect.String && field.opts.Contains("string") && isQuotable(field) {
+		nval, err := unquotedValue(val, typof)
+		if err != nil {
+			return fail(err)
+		}
+		val = nval
+	} else {
+		nval, err := scalarValue(val, typof, m.config.WeaklyTyped)
+		if err != nil {
+			return fail(err)
+		}
+		val = nval
+	}
+	return val, true, nil
 }
 
-/*
-FillStruct acts just like “json.Unmarshal“ but works with “Mapped“
-instead of bytes of char that made from “json“.
-*/
-func FillStruct(obj interface{}, mapped Mapped) error {
-	errmsg := ""
-	mapf := make(map[string]reflect.StructField)
+// fillStruct fills obj from mapped and reports the failing fields
+// as *MultiError.
+func (m *Mapper) fillStruct(obj interface{}, mapped Mapped, tagname string) error {
+	return m.fillStructKeys(obj, mapped, tagname, m.config.ErrorUnused)
+}
+
+// fillStructKeys fills obj from mapped, the keys without field are
+// reported when unused is true.
+func (m *Mapper) fillStructKeys(obj interface{}, mapped Mapped, tagname string, unused bool) error {
+	errs := &MultiError{}
+	sval := extractValue(obj)
+	plan := m.plan(sval.Type(), tagname)
+	var present map[string]bool
+	if len(plan.required) > 0 || len(plan.defaults) > 0 || len(plan.nested) > 0 {
+		present = make(map[string]bool, len(mapped))
+	}
+	report := func(key string, err error) bool {
+		if m.config.ErrorMode == ErrorIgnore {
+			return true
+		}
+		errs.add(key, err)
+		return m.config.ErrorMode != ErrorFailFast
+	}
 	for k, v := range mapped {
+		field, ok := m.lookupField(plan.byKey, k)
+		if !ok {
+			if unused && !report(k, &FieldError{Key: k, Tag: tagname,
+				Value: v, Err: ErrUnusedKey}) {
+				return errs
+			}
+			continue
+		}
 		if v == nil {
 			continue
 		}
-		_, err := setFieldFromTag(obj, "", k, v, mapf)
-		if err != nil {
-			if errmsg != "" {
-				errmsg += ","
-			}
-			errmsg += err.Error()
+		if present != nil {
+			present[field.name] = true
+		}
+		_, err := m.setFieldFromTag(sval, tagname, k, v, field)
+		if err != nil && !report(k, err) {
+			return errs
 		}
 	}
-	if errmsg != "" {
-		return fmt.Errorf(errmsg)
+	if len(plan.defaults) > 0 || len(plan.nested) > 0 {
+		err := m.fillDefaults(sval, plan, tagname, present, nil)
+		if err != nil && !report("", err) {
+			return errs
+		}
 	}
-	return nil
+	for _, f := range plan.required {
+		if !present[f.name] && !report(f.name, &FieldError{Key: f.name, Tag: tagname,
+			Type: f.typ, Err: ErrRequired}) {
+			return errs
+		}
+	}
+	return errs.errorOrNil()
+}
+
+/*
+FillStruct acts just like “json.Unmarshal“ but works with “Mapped“
+instead of bytes of char that made from “json“.
+The failing fields are reported as *MultiError of *FieldError.
+The fields with the required tag option, e.g. `json:"name,required"`,
+are reported with ErrRequired when mapped has no value for them.
+The zero fields with the default tag, e.g. `default:"8080"`, are set to
+it when mapped has no value for them, see FillStructByTags.
+*/
+func FillStruct(obj interface{}, mapped Mapped) error {
+	return defaultMapper.fillStruct(obj, mapped, "")
 }
 
 /*
 FillStructByTags fills the field that has tagname and tagvalue
-instead of Mapped key name.
+instead of Mapped key name. The promoted fields of embedded structs
+are filled too, allocating the nil embedded pointers when needed.
+
+The default tag value is converted with the weakly typed rules of
+FillStructWeak, the time fields use the layouts of the field and the
+slice elements are separated by comma, e.g. `default:"a,b"`.
+The defaults of the nested structs are applied too when mapped has no
+value for them, allocating the nil pointers to such structs.
 */
 func FillStructByTags(obj interface{}, mapped Mapped, tagname string) error {
-	errmsg := ""
-	mapf := make(map[string]reflect.StructField)
-	populateMapFieldsTag(mapf, tagname, obj)
-	for k, v := range mapped {
-		if v == nil {
-			continue
-		}
-		_, err := setFieldFromTag(obj, tagname, k, v, mapf)
-		if err != nil {
-			if errmsg != "" {
-				errmsg += ","
-			}
-			errmsg += err.Error()
-		}
-	}
-	if errmsg != "" {
-		return fmt.Errorf(errmsg)
-	}
-	return nil
+	return defaultMapper.fillStruct(obj, mapped, tagname)
+}
+
+/*
+FillStructWeak is FillStruct with weakly typed conversion. The string
+values are parsed into the numbers, bools, time.Duration and time.Time
+fields while the scalar values are formatted into the string fields.
+This is useful when the values come from query strings, environment
+variables or CSV.
+*/
+func FillStructWeak(obj interface{}, mapped Mapped) error {
+	return weakMapper.fillStruct(obj, mapped, "")
+}
+
+// FillStructByTagsWeak is FillStructByTags with weakly typed conversion
+// just like FillStructWeak.
+func FillStructByTagsWeak(obj interface{}, mapped Mapped, tagname string) error {
+	return weakMapper.fillStruct(obj, mapped, tagname)
 }
 
 // FillStructDeflate fills the nested object from flat map.
 // This works by filling outer struct first and then checking its subsequent object fields.
 func FillStructDeflate(obj interface{}, mapped Mapped, tagname string) error {
-	errmsg := ""
-	err := FillStructByTags(obj, mapped, tagname)
-	if err != nil {
-		errmsg = err.Error()
+	return defaultMapper.fillStructDeflate(obj, mapped, tagname)
+}
+
+// FillStructDeflateWeak is FillStructDeflate with weakly typed conversion
+// just like FillStructWeak.
+func FillStructDeflateWeak(obj interface{}, mapped Mapped, tagname string) error {
+	return weakMapper.fillStructDeflate(obj, mapped, tagname)
+}
+
+func (m *Mapper) fillStructDeflate(obj interface{}, mapped Mapped, tagname string) error {
+	// the keys of flat map are already the full path
+	errs := &MultiError{}
+	err := m.fillStructKeys(obj, mapped, tagname, false)
+	if err != nil && m.config.ErrorMode == ErrorFailFast {
+		return err
+	} else if err != nil {
+		errs.add("", err)
 	}
 	sval := extractValue(obj)
-	for i := 0; i < sval.NumField(); i++ {
-		field := sval.Field(i)
-		kind := field.Kind()
-		if kind == reflect.Struct {
-			res := reflect.New(field.Type()).Elem()
-			if err = FillStructDeflate(res, mapped, tagname); err != nil {
-				if errmsg != "" {
-					errmsg += ", "
-				}
-				errmsg += err.Error()
-				continue
-			}
-			field.Set(res)
-		} else if kind == reflect.Ptr {
-			indirectField := field.Type().Elem()
-			if indirectField.Kind() != reflect.Struct {
-				continue
+	for _, f := range m.plan(sval.Type(), tagname).structs {
+		typ := f.typ
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		res := reflect.New(typ).Elem()
+		if err = m.fillStructDeflate(res, mapped, tagname); err != nil {
+			if m.config.ErrorMode == ErrorFailFast {
+				return err
 			}
-			res := reflect.New(indirectField).Elem()
-			if err = FillStructDeflate(res, mapped, tagname); err != nil {
-				if errmsg != "" {
-					errmsg += ", "
-				}
-				errmsg += err.Error()
-				continue
-			}
-			field.Set(res.Addr())
+			errs.add("", err)
+			continue
 		}
+		if f.typ.Kind() == reflect.Ptr {
+			res = res.Addr()
+		}
+		fieldByIndexAlloc(sval, f.index).Set(res)
 	}
-	if errmsg != "" {
-		return fmt.Errorf(errmsg)
-	}
-	return nil
+	return errs.errorOrNil()
 }
 
-func assignScanner(mapvals []interface{}, tagFields map[string]reflect.StructField,
-	tag string, index int, key string, obj, value interface{}) {
+func assignScanner(mapvals []interface{}, tagFields map[string]field,
+	index int, key string, value interface{}) {
 	switch value.(type) {
 	case int:
 		mapvals[index] = new(int)
@@ -590,29 +831,11 @@ func assignScanner(mapvals []interface{}, tagFields map[string]reflect.StructFie
 		mapvals[index] = new(time.Time)
 	case sql.Scanner, driver.Valuer, Mapped:
 		mapvals[index] = new(interface{})
-		typof := reflect.TypeOf(obj).Elem()
-		if tag == "" {
-			strufield, ok := typof.FieldByName(key)
-			if !ok {
-				return
-			}
-			typof = strufield.Type
-		} else if strufield, ok := tagFields[key]; ok {
-			typof = strufield.Type
-		} else {
-			for i := 0; i < typof.NumField(); i++ {
-				strufield := typof.Field(i)
-				if tagval, ok := strufield.Tag.Lookup(tag); ok {
-					tagFields[key] = strufield
-					if tagHead(tagval) == key {
-						typof = strufield.Type
-						break
-					}
-				}
-			}
+		strufield, ok := tagFields[key]
+		if !ok {
+			return
 		}
-
-		scannerI := reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+		typof := strufield.typ
 		if typof.Implements(scannerI) || reflect.PtrTo(typof).Implements(scannerI) {
 			valx := reflect.New(typof).Elem()
 			mapvals[index] = valx.Addr().Interface()
@@ -622,8 +845,8 @@ func assignScanner(mapvals []interface{}, tagFields map[string]reflect.StructFie
 
 }
 
-func assignValuer(mapres Mapped, tagFields map[string]reflect.StructField,
-	tag, key string, obj, value interface{}) {
+func assignValuer(mapres Mapped, tagFields map[string]field,
+	key string, value interface{}) {
 	switch v := value.(type) {
 	case *int8:
 		mapres[key] = *v
@@ -659,27 +882,11 @@ func assignValuer(mapres Mapped, tagFields map[string]reflect.StructField,
 		mapres[key] = *v
 	case *driver.Valuer:
 	default:
-		typof := reflect.TypeOf(obj).Elem()
-		if tag == "" {
-			strufield, ok := typof.FieldByName(key)
-			if !ok {
-				return
-			}
-			typof = strufield.Type
-		} else if strufield, ok := tagFields[key]; ok {
-			typof = strufield.Type
-		} else {
-			for i := 0; i < typof.NumField(); i++ {
-				strufield := typof.Field(i)
-				if tagval, ok := strufield.Tag.Lookup(tag); ok {
-					if tagHead(tagval) == key {
-						typof = strufield.Type
-						break
-					}
-				}
-			}
+		strufield, ok := tagFields[key]
+		if !ok {
+			return
 		}
-		valuerI := reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+		typof := strufield.typ
 		if typof.Implements(valuerI) || reflect.PtrTo(typof).Implements(valuerI) {
 			valx := reflect.New(typof).Elem()
 			valv := reflect.Indirect(reflect.ValueOf(value))
@@ -703,41 +910,40 @@ field name or field tagged string. The tags can receive the empty string
 "" and then it will map the field name by default.
 */
 func SQLScan(row SQLScanner, obj interface{}, tag string, x ...string) error {
-	mapres := MapTags(obj, tag)
+	return defaultMapper.sqlScan(row, obj, tag, x...)
+}
+
+func (m *Mapper) sqlScan(row SQLScanner, obj interface{}, tag string, x ...string) error {
 	fieldsName := x
 	length := len(x)
+	plan := m.plan(reflect.TypeOf(obj).Elem(), tag)
+	// the values of all fields decide the scan destinations, regardless
+	// of the omitempty options and the output keys
+	sval := extractValue(obj)
+	mapres := make(Mapped, len(plan.fields))
+	for _, f := range plan.fields {
+		if fieldval := fieldByIndex(sval, f.index); fieldval.IsValid() {
+			mapres[f.name] = m.getValTag(fieldval, tag)
+		}
+	}
 	if length == 0 || (length == 1 && x[0] == "*") {
-		typof := reflect.TypeOf(obj).Elem()
-		newfields := make([]string, typof.NumField())
-		length = typof.NumField()
-		for i := 0; i < length; i++ {
-			field := typof.Field(i)
-			if tag == "" {
-				newfields[i] = field.Name
-			} else {
-				if tagval, ok := field.Tag.Lookup(tag); ok {
-					newfields[i] = tagHead(tagval)
-				}
-			}
+		length = len(plan.fields)
+		newfields := make([]string, length)
+		for i, f := range plan.fields {
+			newfields[i] = f.name
 		}
 		fieldsName = newfields
 	}
 	mapvals := make([]interface{}, length)
-	tagFields := make(map[string]reflect.StructField)
+	tagFields := plan.byKey
 	for i, k := range fieldsName {
-		assignScanner(mapvals, tagFields, tag, i, k, obj, mapres[k])
+		assignScanner(mapvals, tagFields, i, k, mapres[k])
 	}
 	if err := row.Scan(mapvals...); err != nil {
 		return err
 	}
 	for i, k := range fieldsName {
-		assignValuer(mapres, tagFields, tag, k, obj, mapvals[i])
-	}
-	var err error
-	if tag == "" {
-		err = FillStruct(obj, mapres)
-	} else {
-		err = FillStructByTags(obj, mapres, tag)
+		assignValuer(mapres, tagFields, k, mapvals[i])
 	}
-	return err
+	return m.fillStruct(obj, mapres, tag)
 }
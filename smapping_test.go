@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -1250,3 +1251,172 @@ func BenchmarkFillStructByTags(b *testing.B) {
 	}
 	_ = s
 }
+
+func TestMapTags_tagOptions(t *testing.T) {
+	type inner struct {
+		Value int `json:"value"`
+	}
+	type options struct {
+		Name      string    `json:"name,omitempty"`
+		Skipped   string    `json:"-"`
+		Dash      string    `json:"-,"`
+		NoName    int       `json:",omitempty"`
+		Count     int       `json:"count,omitempty"`
+		Ptr       *int      `json:"ptr,omitempty"`
+		List      []string  `json:"list,omitempty"`
+		Inner     inner     `json:"inner,omitempty"`
+		ZeroInner inner     `json:"zero_inner,omitzero"`
+		Time      time.Time `json:"time,omitzero"`
+		Kept      string    `json:"kept"`
+	}
+	obj := options{Skipped: "skip", Dash: "dash", NoName: 5}
+	m := MapTags(&obj, "json")
+	for _, key := range []string{"name", "Skipped", "count", "ptr",
+		"list", "zero_inner", "time"} {
+		if _, ok := m[key]; ok {
+			t.Errorf("key %s should be omitted, got %#v", key, m[key])
+		}
+	}
+	for _, key := range []string{"-", "NoName", "inner", "kept"} {
+		if _, ok := m[key]; !ok {
+			t.Errorf("key %s should exist", key)
+		}
+	}
+	if m["-"] != "dash" {
+		t.Errorf("expected dash, got %#v", m["-"])
+	}
+
+	obj = options{Name: "name", Count: 1, Time: toki, ZeroInner: inner{1}}
+	m = MapTagsWithDefault(&obj, "api", "json")
+	for _, key := range []string{"name", "count", "zero_inner", "time"} {
+		if _, ok := m[key]; !ok {
+			t.Errorf("key %s should exist", key)
+		}
+	}
+
+	var target options
+	err := FillStructByTags(&target, Mapped{
+		"name":    "filled",
+		"-":       "dash",
+		"Skipped": "not filled",
+		"NoName":  42,
+	}, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Name != "filled" || target.Dash != "dash" ||
+		target.NoName != 42 || target.Skipped != "" {
+		t.Errorf("wrong filled value %#v", target)
+	}
+}
+
+func TestMapTags_arrayFields(t *testing.T) {
+	type arrays struct {
+		Objs   [2]embedObj  `json:"objs"`
+		Ptrs   [2]*embedObj `json:"ptrs"`
+		Ints   [3]int       `json:"ints"`
+		Matrix [2][2]int    `json:"matrix"`
+	}
+	obj := arrays{
+		Objs:   [2]embedObj{{1, "one", 1.1}, {2, "two", 2.2}},
+		Ptrs:   [2]*embedObj{{FieldInt: 3}, nil},
+		Ints:   [3]int{1, 2, 3},
+		Matrix: [2][2]int{{1, 2}, {3, 4}},
+	}
+	mapped := MapTags(&obj, "json")
+	objs, ok := mapped["objs"].([]interface{})
+	if !ok || len(objs) != 2 || objs[1].(Mapped)["fieldStr"] != "two" {
+		t.Errorf("expected array elements mapped, got %#v", mapped["objs"])
+	}
+	var filled arrays
+	if err := FillStructByTags(&filled, mapped, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if filled.Objs != obj.Objs || filled.Ints != obj.Ints || filled.Matrix != obj.Matrix ||
+		filled.Ptrs[0].FieldInt != 3 || filled.Ptrs[1] != nil {
+		t.Errorf("expected %#v, got %#v", obj, filled)
+	}
+
+	filled = arrays{}
+	err := FillStructByTags(&filled, Mapped{
+		"ints": []interface{}{1, 2},
+		"objs": []interface{}{Mapped{"fieldInt": 1}, Mapped{"fieldInt": 2}},
+	}, "json")
+	if err == nil || !strings.Contains(err.Error(), "array length mismatch") {
+		t.Errorf("expected length mismatch error, got %v", err)
+	}
+	if filled.Objs[1].FieldInt != 2 {
+		t.Errorf("expected objs filled, got %#v", filled.Objs)
+	}
+}
+
+type strictRow struct {
+	name string
+	age  int64
+}
+
+func (r strictRow) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		switch p := d.(type) {
+		case *string:
+			*p = r.name
+		case *int64:
+			*p = r.age
+		default:
+			return fmt.Errorf("unsupported destination %d: %T", i, d)
+		}
+	}
+	return nil
+}
+
+func TestSQLScan_omitempty(t *testing.T) {
+	type person struct {
+		Name string `db:"name,omitempty"`
+		Age  int64  `db:"age,omitzero"`
+	}
+	var result person
+	if err := SQLScan(strictRow{name: "Ann", age: 30}, &result, "db"); err != nil {
+		t.Fatal(err)
+	}
+	if result.Name != "Ann" || result.Age != 30 {
+		t.Errorf("wrong scanned %#v", result)
+	}
+}
+
+func TestMapTags_stringOption(t *testing.T) {
+	type quoted struct {
+		D time.Duration `json:"d,string"`
+		N int64         `json:"n,string"`
+		F float64       `json:"f,string"`
+		B *bool         `json:"b,string"`
+		S string        `json:"s,string"`
+		P *int          `json:"p,string"`
+	}
+	yes := true
+	obj := quoted{D: time.Second, N: 5, F: 1.5, B: &yes, S: "plain"}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected Mapped
+	if err := json.Unmarshal(data, &expected); err != nil {
+		t.Fatal(err)
+	}
+	// the string option of string fields is left to encoding/json
+	expected["s"] = "plain"
+	mapped := MapTags(&obj, "json")
+	if !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("expected %#v, got %#v", expected, mapped)
+	}
+
+	var filled quoted
+	if err := FillStructByTags(&filled, mapped, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filled, obj) {
+		t.Errorf("expected %#v, got %#v", obj, filled)
+	}
+	if err := FillStructByTags(&filled, Mapped{"n": "five"}, "json"); err == nil {
+		t.Errorf("expected the invalid quoted number error")
+	}
+}
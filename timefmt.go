@@ -0,0 +1,221 @@
+package smapping
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	s "strings"
+	"time"
+)
+
+// namedLayouts are the layouts that can be referred by its name in the
+// layout tag option, e.g. `json:"at,layout=RFC1123"`, since the tag
+// options can't contain commas.
+var namedLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+// epochLayouts are the pseudo layouts of the Unix epoch in its unit.
+var epochLayouts = map[string]time.Duration{
+	"unix":      time.Second,
+	"unixmilli": time.Millisecond,
+	"unixmicro": time.Microsecond,
+	"unixnano":  time.Nanosecond,
+}
+
+// Get returns the value of the option in form of name=value.
+func (o tagOptions) Get(name string) (string, bool) {
+	for _, opt := range s.Split(string(o), ",") {
+		if s.HasPrefix(opt, name+"=") {
+			return opt[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
+func resolveLayout(layout string) string {
+	if named, ok := namedLayouts[layout]; ok {
+		return named
+	}
+	return layout
+}
+
+// fieldLayouts returns the layout of the field option or the configured
+// layouts otherwise.
+func (m *Mapper) fieldLayouts(opts tagOptions) []string {
+	if layout, ok := opts.Get("layout"); ok {
+		return []string{layout}
+	}
+	return m.timeLayouts()
+}
+
+// epochUnit returns the unit of the first epoch layout, the numbers
+// are in seconds when there's none.
+func epochUnit(layouts []string) time.Duration {
+	for _, layout := range layouts {
+		if unit, ok := epochLayouts[layout]; ok {
+			return unit
+		}
+	}
+	return time.Second
+}
+
+// epochTime returns the time of n units since the epoch, it doesn't
+// overflow for the times outside of the time.Duration range.
+func epochTime(n int64, unit time.Duration) time.Time {
+	switch unit {
+	case time.Second:
+		return time.Unix(n, 0).UTC()
+	case time.Millisecond:
+		return time.UnixMilli(n).UTC()
+	case time.Microsecond:
+		return time.UnixMicro(n).UTC()
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// floatTime returns the time of the fractional f units since the epoch.
+func floatTime(f float64, unit time.Duration) time.Time {
+	secs := f * float64(unit) / float64(time.Second)
+	whole := math.Floor(secs)
+	return time.Unix(int64(whole), int64((secs-whole)*float64(time.Second))).UTC()
+}
+
+// parseTime parses the string with the layouts tried in order.
+func parseTime(layouts []string, str string) (time.Time, error) {
+	var (
+		t   time.Time
+		err error
+	)
+	for _, layout := range layouts {
+		if unit, ok := epochLayouts[layout]; ok {
+			var n int64
+			if n, err = strconv.ParseInt(str, 10, 64); err == nil {
+				return epochTime(n, unit), nil
+			}
+			err = fmt.Errorf("parsing time %q as %s: %s", str, layout, err.Error())
+			continue
+		}
+		if t, err = time.Parse(resolveLayout(layout), str); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
+}
+
+// numberTime converts the number val to the time of epoch in unit.
+func numberTime(val reflect.Value, unit time.Duration) (time.Time, error) {
+	if val.Type() == jsonNumberType {
+		n, err := strconv.ParseInt(val.String(), 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(val.String(), 64)
+			if ferr != nil {
+				return time.Time{}, err
+			}
+			return floatTime(f, unit), nil
+		}
+		return epochTime(n, unit), nil
+	}
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return epochTime(val.Int(), unit), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return epochTime(int64(val.Uint()), unit), nil
+	}
+	return floatTime(val.Float(), unit), nil
+}
+
+// formatTime formats t with the layout, the epoch layouts are formatted
+// as int64.
+func formatTime(t time.Time, layout string) interface{} {
+	if unit, ok := epochLayouts[layout]; ok {
+		switch unit {
+		case time.Second:
+			return t.Unix()
+		case time.Millisecond:
+			return t.UnixMilli()
+		case time.Microsecond:
+			return t.UnixMicro()
+		}
+		return t.UnixNano()
+	}
+	return t.Format(resolveLayout(layout))
+}
+
+// fillTime converts the string, the number of epoch or the time value
+// into the time field.
+func (m *Mapper) fillTime(field field, val *reflect.Value) error {
+	var (
+		t   time.Time
+		err error
+	)
+	layouts := m.fieldLayouts(field.opts)
+	switch {
+	case val.Kind() == reflect.String && val.Type() != jsonNumberType:
+		if t, err = parseTime(layouts, val.String()); err != nil {
+			return fmt.Errorf("smapping Time conversion: time conversion: %s", err.Error())
+		}
+	case isNumeric(val.Type()):
+		if t, err = numberTime(*val, epochUnit(layouts)); err != nil {
+			return fmt.Errorf("smapping Time conversion: %s", err.Error())
+		}
+	case isTime(val.Type()):
+		if val.Type() == field.typ {
+			return nil
+		}
+		if val.Kind() == reflect.Ptr && val.IsNil() {
+			*val = reflect.Zero(field.typ)
+			return nil
+		}
+		var ok bool
+		if t, ok = reflect.Indirect(*val).Interface().(time.Time); !ok {
+			return ErrTypeNotMatch
+		}
+	default:
+		return ErrTypeNotMatch
+	}
+	if field.typ.Kind() == reflect.Ptr {
+		*val = reflect.ValueOf(&t)
+	} else {
+		*val = reflect.ValueOf(t)
+	}
+	return nil
+}
+
+// timeValue returns the time or duration field value formatted as
+// the field options, it's not ok when there's no such option. The
+// duration is formatted such as "1h30m0s" with `json:"d,duration=string"`.
+func timeValue(f field, fieldval reflect.Value) (interface{}, bool) {
+	if format, ok := f.opts.Get("duration"); ok && format == "string" && f.typ == durationType {
+		return time.Duration(fieldval.Int()).String(), true
+	}
+	if !f.caps.isTime {
+		return nil, false
+	}
+	layout, ok := f.opts.Get("layout")
+	if !ok {
+		return nil, false
+	}
+	if isValueNil(fieldval) {
+		return nil, true
+	}
+	t, ok := reflect.Indirect(fieldval).Interface().(time.Time)
+	if !ok {
+		return nil, false
+	}
+	return formatTime(t, layout), true
+}
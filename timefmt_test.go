@@ -0,0 +1,122 @@
+package smapping
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type timeFields struct {
+	Born     time.Time     `json:"born,layout=2006-01-02"`
+	Seen     *time.Time    `json:"seen,layout=RFC1123"`
+	Created  time.Time     `json:"created,layout=unix"`
+	Updated  time.Time     `json:"updated,layout=unixmilli"`
+	Plain    time.Time     `json:"plain"`
+	Timeout  time.Duration `json:"timeout,duration=string"`
+	Interval time.Duration `json:"interval"`
+}
+
+func TestMapTags_timeLayouts(t *testing.T) {
+	at := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	obj := timeFields{
+		Born:     at,
+		Seen:     &at,
+		Created:  at,
+		Updated:  at.Add(8 * time.Millisecond),
+		Plain:    at,
+		Timeout:  90 * time.Minute,
+		Interval: time.Second,
+	}
+	mapped := MapTags(&obj, "json")
+	expected := Mapped{
+		"born":     "2021-03-04",
+		"seen":     "Thu, 04 Mar 2021 05:06:07 UTC",
+		"created":  at.Unix(),
+		"updated":  at.UnixNano()/int64(time.Millisecond) + 8,
+		"plain":    at,
+		"timeout":  "1h30m0s",
+		"interval": time.Second,
+	}
+	if !reflect.DeepEqual(mapped, expected) {
+		t.Errorf("expected %#v, got %#v", expected, mapped)
+	}
+
+	if seen, ok := MapTags(&timeFields{}, "json")["seen"]; !ok || seen != nil {
+		t.Errorf("expected nil seen for the nil pointer, got %#v", seen)
+	}
+
+	var filled timeFields
+	if err := FillStructByTags(&filled, mapped, "json"); err != nil {
+		t.Fatal(err)
+	}
+	obj.Born = time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	if !filled.Born.Equal(obj.Born) || !filled.Seen.Equal(at) ||
+		!filled.Created.Equal(at) || !filled.Updated.Equal(obj.Updated) ||
+		filled.Timeout != obj.Timeout || filled.Interval != obj.Interval {
+		t.Errorf("expected %#v, got %#v", obj, filled)
+	}
+}
+
+func TestFillStruct_timeValues(t *testing.T) {
+	var mapped Mapped
+	decoder := json.NewDecoder(strings.NewReader(`{
+		"created": 1614834367,
+		"updated": "1614834367008",
+		"plain": 1614834367.5,
+		"interval": "1m30s"
+	}`))
+	decoder.UseNumber()
+	if err := decoder.Decode(&mapped); err != nil {
+		t.Fatal(err)
+	}
+	var obj timeFields
+	if err := FillStructByTags(&obj, mapped, "json"); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if !obj.Created.Equal(at) || !obj.Updated.Equal(at.Add(8*time.Millisecond)) ||
+		!obj.Plain.Equal(at.Add(500*time.Millisecond)) || obj.Interval != 90*time.Second {
+		t.Errorf("wrong filled times %#v", obj)
+	}
+
+	mapper := NewMapper(Config{
+		TagName:     "json",
+		TimeLayouts: []string{"unixmilli", "DateOnly", time.RFC1123},
+	})
+	obj = timeFields{}
+	err := mapper.FillStruct(&obj, Mapped{
+		"plain":   "Thu, 04 Mar 2021 05:06:07 UTC",
+		"created": "2021-03-04",
+	})
+	if err == nil || !strings.Contains(err.Error(), "created") {
+		t.Errorf("expected the created layout error, got %v", err)
+	}
+	if !obj.Plain.Equal(at) {
+		t.Errorf("expected plain parsed with RFC1123, got %v", obj.Plain)
+	}
+	if err := mapper.FillStruct(&obj, Mapped{"plain": 1614834367008}); err != nil {
+		t.Fatal(err)
+	}
+	if !obj.Plain.Equal(at.Add(8 * time.Millisecond)) {
+		t.Errorf("expected plain parsed as unixmilli, got %v", obj.Plain)
+	}
+
+	if err := FillStructByTags(&obj, Mapped{"interval": "soon"}, "json"); err == nil {
+		t.Errorf("expected duration parse error")
+	}
+
+	// the epochs out of the time.Duration range
+	far := time.Date(2286, 11, 20, 17, 46, 40, 0, time.UTC)
+	if err := FillStructByTags(&obj, Mapped{"created": int64(1e10), "plain": 1e10}, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !obj.Created.Equal(far) || !obj.Plain.Equal(far) {
+		t.Errorf("expected %v, got %v and %v", far, obj.Created, obj.Plain)
+	}
+	zero, epoch := MapTags(&timeFields{}, "json"), time.Time{}
+	if zero["created"] != epoch.Unix() || zero["updated"] != epoch.UnixMilli() {
+		t.Errorf("wrong epochs of the zero time %v and %v", zero["created"], zero["updated"])
+	}
+}
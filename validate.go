@@ -0,0 +1,339 @@
+package smapping
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	s "strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidatorFunc checks the field value against the param of its rule
+// in the validate tag, e.g. "max" with "10" for `validate:"max=10"`.
+// The pointers are dereferenced and the nil pointers are only checked
+// by the required rule.
+type ValidatorFunc func(value interface{}, param string) error
+
+// ValidationError is the cause of FieldError when the field fails the
+// rule of the validate tag.
+type ValidationError struct {
+	// Rule is the name of the failing rule.
+	Rule string
+	// Param is the param of the rule, empty when there's none.
+	Param string
+	// Err is the error returned by the rule.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("validate %s: %s", e.Rule, e.Err)
+	}
+	return fmt.Sprintf("validate %s=%s: %s", e.Rule, e.Param, e.Err)
+}
+
+// Unwrap returns the error of the rule.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+type validatorFunc func(val reflect.Value, param string) error
+
+// validators are the built-in rules, the value is the dereferenced
+// field value.
+var validators = map[string]validatorFunc{
+	"min":   validateMin,
+	"max":   validateMax,
+	"oneof": validateOneOf,
+	"regex": validateRegex,
+}
+
+/*
+RegisterValidator registers the rule name of the validate tag. The
+built-in rules required, min, max, oneof and regex can be replaced,
+except for required.
+*/
+func (m *Mapper) RegisterValidator(name string, fn ValidatorFunc) {
+	r := &m.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.validators == nil {
+		r.validators = make(map[string]ValidatorFunc)
+	}
+	r.validators[name] = fn
+}
+
+func (m *Mapper) validatorOf(name string) (validatorFunc, bool) {
+	r := &m.registry
+	r.mu.RLock()
+	fn, ok := r.validators[name]
+	r.mu.RUnlock()
+	if ok {
+		return func(val reflect.Value, param string) error {
+			return fn(val.Interface(), param)
+		}, true
+	}
+	builtin, ok := validators[name]
+	return builtin, ok
+}
+
+/*
+Validate checks the fields of the struct obj against the rules of its
+validate tag, including the nested structs and the structs in slices
+and maps. The fields without key for the tag are validated too, with
+its field name as the key. The rules are separated by comma and the param follows the
+equal sign, for example `validate:"required,min=1,max=10"`:
+
+	required	the value isn't the zero value
+	min, max	the number, the length of string, slice or map, or the duration
+	oneof		the value is one of the params separated by space, e.g. oneof=a b
+	regex		the string matches the regular expression
+
+The regex rule takes the rest of the tag as its param so it must be the
+last rule. The rules are checked in order and the first failing rule of
+each field is reported as *FieldError of *MultiError with the
+*ValidationError cause and the key path of the tag.
+*/
+func Validate(obj interface{}, tag string) error {
+	return defaultMapper.validate(obj, tag)
+}
+
+// Validate checks obj just like the package level Validate with the
+// configured tags and the registered validators.
+func (m *Mapper) Validate(obj interface{}) error {
+	return m.validate(obj, m.config.TagName)
+}
+
+// validated runs the validation of obj after the fill failing with err
+// when the Mapper is configured to validate.
+func (m *Mapper) validated(obj interface{}, tag string, err error) error {
+	if !m.config.Validate || m.config.ErrorMode == ErrorIgnore ||
+		err != nil && m.config.ErrorMode == ErrorFailFast {
+		return err
+	}
+	verr := m.validate(obj, tag)
+	if err == nil || verr == nil {
+		if verr != nil {
+			return verr
+		}
+		return err
+	}
+	errs := &MultiError{}
+	errs.add("", err)
+	errs.add("", verr)
+	return errs
+}
+
+func (m *Mapper) validate(obj interface{}, tag string) error {
+	errs := m.validateStruct(extractValue(obj), tag)
+	if m.config.ErrorMode == ErrorFailFast && len(errs.Errors) > 1 {
+		errs.Errors = errs.Errors[:1]
+	}
+	return errs.errorOrNil()
+}
+
+func (m *Mapper) validateStruct(sval reflect.Value, tag string) *MultiError {
+	errs := &MultiError{}
+	plan := m.plan(sval.Type(), tag)
+	fields := plan.fields
+	if len(plan.unmapped) > 0 {
+		fields = append(append([]field(nil), fields...), plan.unmapped...)
+	}
+	for _, f := range fields {
+		fv := fieldByIndex(sval, f.index)
+		if !fv.IsValid() {
+			continue
+		}
+		if f.rules != "" {
+			if err := m.validateRules(fv, f.rules); err != nil {
+				errs.add(f.name, &FieldError{Key: f.name, Tag: tag,
+					Type: f.typ, Value: fv.Interface(), Err: err})
+			}
+		}
+		if err := m.validateNested(fv, tag); err != nil {
+			errs.add(f.name, err)
+		}
+	}
+	return errs
+}
+
+// validateNested validates the nested structs of the field value.
+func (m *Mapper) validateNested(fv reflect.Value, tag string) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	errs := &MultiError{}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if nestedStruct(fv.Type()) {
+			return m.validateStruct(fv, tag).errorOrNil()
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := m.validateNested(fv.Index(i), tag); err != nil {
+				errs.add("["+strconv.Itoa(i)+"]", err)
+			}
+		}
+	case reflect.Map:
+		iter := fv.MapRange()
+		for iter.Next() {
+			if err := m.validateNested(iter.Value(), tag); err != nil {
+				errs.add(mapKeyString(iter.Key()), err)
+			}
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// validateRules checks the field value against the rules in order,
+// returning the *ValidationError of the first failing rule.
+func (m *Mapper) validateRules(fv reflect.Value, rules string) error {
+	for rules != "" {
+		rule := rules
+		if i := s.Index(rules, ","); i >= 0 && !s.HasPrefix(rules, "regex=") {
+			rule, rules = rules[:i], rules[i+1:]
+		} else {
+			rules = ""
+		}
+		name, param := rule, ""
+		if i := s.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		if name == "" {
+			continue
+		}
+		var err error
+		if name == "required" {
+			if fv.IsZero() {
+				err = errors.New("value is required")
+			}
+		} else if val := reflect.Indirect(fv); val.IsValid() {
+			if fn, ok := m.validatorOf(name); ok {
+				err = fn(val, param)
+			} else {
+				err = fmt.Errorf("unknown validator %q", name)
+			}
+		}
+		if err != nil {
+			return &ValidationError{Rule: name, Param: param, Err: err}
+		}
+	}
+	return nil
+}
+
+// validateSize compares the number, the length or the duration of val
+// with param, cmp reports whether the sign of val - param is valid.
+func validateSize(val reflect.Value, param string, cmp func(int) bool, msg string) error {
+	var sign int
+	if val.Type() == durationType {
+		d, err := time.ParseDuration(param)
+		if err != nil {
+			return err
+		}
+		sign = compareFloat(float64(val.Int()), float64(d))
+		if !cmp(sign) {
+			return fmt.Errorf("must be %s %s", msg, param)
+		}
+		return nil
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return err
+	}
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sign = compareFloat(float64(val.Int()), limit)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		sign = compareFloat(float64(val.Uint()), limit)
+	case reflect.Float32, reflect.Float64:
+		sign = compareFloat(val.Float(), limit)
+	case reflect.String:
+		if val.Type() == jsonNumberType {
+			f, err := strconv.ParseFloat(val.String(), 64)
+			if err != nil {
+				return err
+			}
+			sign = compareFloat(f, limit)
+			break
+		}
+		sign = compareFloat(float64(utf8.RuneCountInString(val.String())), limit)
+		msg += " length"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		sign = compareFloat(float64(val.Len()), limit)
+		msg += " length"
+	default:
+		return fmt.Errorf("not supported for %v", val.Type())
+	}
+	if !cmp(sign) {
+		return fmt.Errorf("must be %s %s", msg, param)
+	}
+	return nil
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func validateMin(val reflect.Value, param string) error {
+	return validateSize(val, param, func(sign int) bool { return sign >= 0 }, "at least")
+}
+
+func validateMax(val reflect.Value, param string) error {
+	return validateSize(val, param, func(sign int) bool { return sign <= 0 }, "at most")
+}
+
+func validateOneOf(val reflect.Value, param string) error {
+	var str string
+	switch val.Kind() {
+	case reflect.String:
+		str = val.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		str = fmt.Sprint(val.Interface())
+	default:
+		return fmt.Errorf("not supported for %v", val.Type())
+	}
+	for _, option := range s.Fields(param) {
+		if str == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", param)
+}
+
+// regexps caches the compiled *regexp.Regexp by its expression.
+var regexps sync.Map
+
+func validateRegex(val reflect.Value, param string) error {
+	if val.Kind() != reflect.String {
+		return fmt.Errorf("not supported for %v", val.Type())
+	}
+	var re *regexp.Regexp
+	if cached, ok := regexps.Load(param); ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		var err error
+		if re, err = regexp.Compile(param); err != nil {
+			return err
+		}
+		regexps.Store(param, re)
+	}
+	if !re.MatchString(val.String()) {
+		return fmt.Errorf("must match %s", param)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package smapping
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validatedItem struct {
+	Name string `json:"name" validate:"required,regex=^[a-z]+(,[a-z]+)*$"`
+	Qty  int    `json:"qty" validate:"min=1,max=10"`
+}
+
+type validatedOrder struct {
+	ID      string          `json:"id" validate:"required,even"`
+	Status  string          `json:"status" validate:"oneof=new paid"`
+	Items   []validatedItem `json:"items" validate:"min=1"`
+	Note    *string         `json:"note" validate:"max=5"`
+	Timeout time.Duration   `json:"timeout" validate:"max=1m"`
+	Owner   *validatedItem  `json:"owner"`
+}
+
+func ExampleMapper_RegisterValidator() {
+	type user struct {
+		Name string `json:"name" validate:"required,lower"`
+		Age  int    `json:"age" validate:"min=18"`
+	}
+	mapper := NewMapper(Config{TagName: "json", Validate: true})
+	mapper.RegisterValidator("lower", func(value interface{}, _ string) error {
+		if str := value.(string); str != strings.ToLower(str) {
+			return fmt.Errorf("%q is not lower case", str)
+		}
+		return nil
+	})
+	var u user
+	err := mapper.FillStruct(&u, Mapped{"name": "Alice", "age": 17})
+	var merr *MultiError
+	if errors.As(err, &merr) {
+		for _, fe := range merr.Errors {
+			fmt.Println(fe.Path, fe.Err)
+		}
+	}
+
+	// Output:
+	// name validate lower: "Alice" is not lower case
+	// age validate min=18: must be at least 18
+}
+
+func TestValidate(t *testing.T) {
+	mapper := NewMapper(Config{TagName: "json", Validate: true})
+	mapper.RegisterValidator("even", func(value interface{}, _ string) error {
+		if len(value.(string))%2 != 0 {
+			return errors.New("odd length")
+		}
+		return nil
+	})
+	var order validatedOrder
+	err := mapper.FillStruct(&order, Mapped{
+		"id":     "ab",
+		"status": "new",
+		"items": []Mapped{
+			{"name": "apple,kiwi", "qty": 3},
+		},
+		"timeout": "30s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mapper.FillStruct(&order, Mapped{
+		"id":     "abc",
+		"status": "lost",
+		"items": []Mapped{
+			{"name": "apple", "qty": 3},
+			{"name": "Kiwi", "qty": 11},
+		},
+		"note":    "too long",
+		"timeout": "2m",
+		"owner":   Mapped{"qty": 1},
+		"unknown": true,
+	})
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("expected *MultiError, got %v", err)
+	}
+	var paths []string
+	for _, fe := range merr.Errors {
+		var verr *ValidationError
+		if !errors.As(fe, &verr) {
+			t.Errorf("expected *ValidationError, got %v", fe)
+			continue
+		}
+		paths = append(paths, fe.Path+":"+verr.Rule)
+	}
+	expected := "id:even status:oneof items[1].name:regex items[1].qty:max " +
+		"note:max timeout:max owner.name:required"
+	if strings.Join(paths, " ") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(paths, " "))
+	}
+
+	err = Validate(&validatedOrder{ID: "ab", Items: []validatedItem{{Name: "a", Qty: 1}}}, "json")
+	if err == nil || !strings.Contains(err.Error(), `unknown validator "even"`) {
+		t.Errorf("expected the unknown validator error, got %v", err)
+	}
+	err = Validate(&validatedItem{Name: "a", Qty: 1}, "json")
+	if err != nil {
+		t.Errorf("expected valid item, got %v", err)
+	}
+
+	// the fields without json key are validated by its name
+	var settings struct {
+		Name   string        `json:"name"`
+		Level  int           `validate:"min=1"`
+		Hidden string        `json:"-" validate:"required"`
+		Owner  validatedItem `json:"-"`
+	}
+	err = NewMapper(Config{TagName: "json", Validate: true}).
+		FillStruct(&settings, Mapped{"name": "x"})
+	paths = nil
+	if errors.As(err, &merr) {
+		for _, fe := range merr.Errors {
+			paths = append(paths, fe.Path)
+		}
+	}
+	if strings.Join(paths, " ") != "Level Hidden Owner.name Owner.qty" {
+		t.Errorf("expected the unmapped fields validated, got %v", err)
+	}
+}